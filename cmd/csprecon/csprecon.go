@@ -7,12 +7,43 @@ This repository is under MIT License https://github.com/edoardottt/csprecon/blob
 package main
 
 import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+
 	"github.com/edoardottt/csprecon/pkg/csprecon"
 	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/projectdiscovery/gologger"
 )
 
 func main() {
-	options := input.ParseOptions()
-	runner := csprecon.New(options)
-	runner.Run()
+	if err := run(input.ParseOptions()); err != nil {
+		gologger.Fatal().Msgf("%s", err)
+	}
+}
+
+// run writes the results on the screen and to the output file, if any.
+func run(options *input.Options) error {
+	options.Output = os.Stdout
+
+	if options.FileOutput != "" {
+		file, err := os.Create(options.FileOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		options.Output = io.MultiWriter(os.Stdout, file)
+	}
+
+	runner, err := csprecon.New(options)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return runner.Run(ctx)
 }
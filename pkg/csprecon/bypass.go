@@ -0,0 +1,151 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	TechniqueJSONP       = "jsonp"
+	TechniqueAngularJS   = "angularjs"
+	TechniqueUserContent = "user-content"
+	// wildcardProbeLabel is used to check whether a source allows
+	// an arbitrary subdomain of a wildcard bypass entry.
+	wildcardProbeLabel = "csprecon-probe"
+)
+
+//go:embed data/bypasses.json
+var embeddedBypassDB []byte
+
+// BypassEntry is a known host (and optional path) that can be
+// abused to execute arbitrary scripts when allowlisted.
+// A host starting with "*." means that anyone can get a subdomain.
+type BypassEntry struct {
+	Host      string `json:"host"`
+	Path      string `json:"path"`
+	Technique string `json:"technique"`
+}
+
+// BypassDB is a dataset of known bypassable hosts.
+type BypassDB []BypassEntry
+
+// Bypass is an allowlisted source enabling a bypass technique.
+type Bypass struct {
+	Directive string
+	Origin    string
+	Source    Source
+	Technique string
+	Example   string
+}
+
+// String returns the bypass as a single line.
+func (b Bypass) String() string {
+	return fmt.Sprintf("[%s] %s %s (%s): %s enables the %s bypass via //%s",
+		SeverityHigh, RuleAllowlistBypass, b.Directive, b.Origin, b.Source.Expression, b.Technique, b.Example)
+}
+
+// DefaultBypassDB returns the embedded dataset of known bypassable hosts.
+func DefaultBypassDB() BypassDB {
+	db, err := ParseBypassDB(embeddedBypassDB)
+	if err != nil {
+		return BypassDB{}
+	}
+
+	return db
+}
+
+// LoadBypassDB reads a dataset of known bypassable hosts from a JSON file.
+func LoadBypassDB(path string) (BypassDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseBypassDB(data)
+}
+
+// ParseBypassDB parses a JSON dataset of known bypassable hosts.
+func ParseBypassDB(data []byte) (BypassDB, error) {
+	db := BypassDB{}
+
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+
+	for i := range db {
+		db[i].Host = strings.ToLower(db[i].Host)
+	}
+
+	return db, nil
+}
+
+// FindBypasses returns the sources allowlisted for scripts
+// matching an entry of the dataset. Each source is reported
+// once per technique.
+func FindBypasses(policies []Policy, db BypassDB) []Bypass {
+	result := []Bypass{}
+
+	for _, policy := range policies {
+		d, ok := policy.Get("script-src")
+		if !ok {
+			d, ok = policy.Get("default-src")
+		}
+
+		// 'strict-dynamic' makes browsers ignore allowlists.
+		if !ok || hasKeyword(d, "strict-dynamic") {
+			continue
+		}
+
+		for _, s := range d.Sources {
+			// '*' allows everything and it's already reported by the evaluator.
+			if s.Type != SourceHost || s.Host == "*" {
+				continue
+			}
+
+			seen := map[string]struct{}{}
+
+			for _, entry := range db {
+				if _, ok := seen[entry.Technique]; ok || !entry.matches(s) {
+					continue
+				}
+
+				seen[entry.Technique] = struct{}{}
+				result = append(result, Bypass{
+					Directive: d.Name,
+					Origin:    policy.Origin,
+					Source:    s,
+					Technique: entry.Technique,
+					Example:   strings.Replace(entry.Host, "*", wildcardProbeLabel, 1) + entry.Path,
+				})
+			}
+		}
+	}
+
+	return result
+}
+
+// matches reports whether the source allows the entry URL,
+// following the CSP host-source and path matching rules.
+func (e BypassEntry) matches(s Source) bool {
+	return hostCovers(s.Host, strings.Replace(e.Host, "*", wildcardProbeLabel, 1)) && pathCovers(s.Path, e.Path)
+}
+
+// hasKeyword reports whether the directive contains the keyword.
+func hasKeyword(d Directive, keyword string) bool {
+	for _, s := range d.Sources {
+		if s.Type == SourceKeyword && s.Value == keyword {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindBypasses(t *testing.T) {
+	db := csprecon.BypassDB{
+		{Host: "ajax.googleapis.com", Path: "/ajax/services/feed/find", Technique: csprecon.TechniqueJSONP},
+		{Host: "ajax.googleapis.com", Path: "/ajax/libs/angularjs/1.1.5/angular.min.js", Technique: csprecon.TechniqueAngularJS},
+		{Host: "*.s3.amazonaws.com", Technique: csprecon.TechniqueUserContent},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "no script directive",
+			input: "img-src *.googleapis.com",
+			want:  []string{},
+		},
+		{
+			name:  "wildcard host",
+			input: "script-src 'self' https://*.googleapis.com",
+			want:  []string{csprecon.TechniqueJSONP, csprecon.TechniqueAngularJS},
+		},
+		{
+			name:  "default-src fallback and path",
+			input: "default-src ajax.googleapis.com/ajax/libs/",
+			want:  []string{csprecon.TechniqueAngularJS},
+		},
+		{
+			name:  "exact path",
+			input: "script-src ajax.googleapis.com/ajax/libs",
+			want:  []string{},
+		},
+		{
+			name:  "user content",
+			input: "script-src https://*.amazonaws.com mybucket.s3.amazonaws.com",
+			want:  []string{csprecon.TechniqueUserContent},
+		},
+		{
+			name:  "strict-dynamic",
+			input: "script-src 'nonce-abc' 'strict-dynamic' https://*.googleapis.com",
+			want:  []string{},
+		},
+		{
+			name:  "star",
+			input: "script-src *",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, b := range csprecon.FindBypasses(csprecon.ParsePolicyList(tt.input), db) {
+				got = append(got, b.Technique)
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBypassDB(t *testing.T) {
+	require.NotEmpty(t, csprecon.DefaultBypassDB())
+
+	path := filepath.Join(t.TempDir(), "bypasses.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"host": "CDN.Example.com", "path": "/jsonp", "technique": "jsonp"}]`), 0o600))
+
+	db, err := csprecon.LoadBypassDB(path)
+	require.NoError(t, err)
+	require.Equal(t, csprecon.BypassDB{{Host: "cdn.example.com", Path: "/jsonp", Technique: "jsonp"}}, db)
+
+	_, err = csprecon.ParseBypassDB([]byte(`{`))
+	require.Error(t, err)
+}
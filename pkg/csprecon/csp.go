@@ -7,18 +7,22 @@ This repository is under MIT License https://github.com/edoardottt/csprecon/blob
 package csprecon
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"io"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/edoardottt/csprecon/pkg/input"
-	"github.com/edoardottt/golazy"
-	"github.com/projectdiscovery/gologger"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 const (
@@ -26,85 +30,402 @@ const (
 	KeepAlive           = 30
 	DomainRegex         = `(?i)(?:[_a-z0-9\*](?:[_a-z0-9-\*]{0,61}[a-z0-9])?\.)+(?:[a-z](?:[a-z0-9-]{0,61}[a-z0-9]))+`
 	MinURLLength        = 4
-	MaxKBBodyReader     = 500 * 1024 // Limit reading to the first 500KB of the HTML body
-	MaxIdleConns        = 100
-	MaxIdleConnsPerHost = 10
+	MaxKBBodyReader     = 500 * 1024 // Default limit of the HTML body read (500KB)
+	KB                  = 1024
 	IdleConnTimeout     = 90
+	byteOrderMark       = "\ufeff"
 )
 
-// CheckCSP returns the list of domains parsed from a URL found in CSP.
-func CheckCSP(url, ua string, rCSP *regexp.Regexp, client *http.Client) ([]string, error) {
-	result := []string{}
+const (
+	HeaderCSP           = "Content-Security-Policy"
+	HeaderCSPReportOnly = "Content-Security-Policy-Report-Only"
+	HeaderXCSP          = "X-Content-Security-Policy"
+	HeaderXWebKitCSP    = "X-WebKit-CSP"
+)
 
-	gologger.Debug().Msgf("Checking CSP for %s", url)
+// RequestOptions configures how CheckCSP requests a URL.
+// Headers are sent with every request, the ones carrying
+// credentials only to the host of the input URL.
+// At most BodyLimit bytes of the body are read (0 means headers only),
+// non-HTML bodies are skipped unless ForceBody is true.
+// Redirects is one of input.RedirectNone, input.RedirectSameHost
+// and input.RedirectAll, following at most MaxRedirects redirects.
+// Extractors are run on every response, the body (whatever its type)
+// is handed up to BodyLimit bytes to the ones implementing BodyExtractor.
+// Nothing is logged if Logger is nil.
+type RequestOptions struct {
+	UserAgent    string
+	Headers      http.Header
+	BodyLimit    int64
+	ForceBody    bool
+	Redirects    string
+	MaxRedirects int
+	Extractors   []Extractor
+	Logger       Logger
+}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return result, err
+// logger returns the logger of the options.
+func (o RequestOptions) logger() Logger {
+	if o.Logger == nil {
+		return NopLogger{}
+	}
+
+	return o.Logger
+}
+
+// Hop is a redirect response, with the policies of its headers.
+type Hop struct {
+	URL        string
+	StatusCode int
+	Location   string
+	Header     http.Header
+	Policies   []Policy
+}
+
+// CSPResponse holds the policies and the reporting endpoints
+// collected from the final response of a URL, and the redirect
+// responses preceding it. Findings are the ones of the extractors.
+// BodyTruncated is true when the body limit was reached before the
+// end of the head (of the body, if an extractor needs it).
+type CSPResponse struct {
+	URL             string
+	StatusCode      int
+	Header          http.Header
+	Policies        []Policy
+	ReportEndpoints []ReportEndpoint
+	Hops            []Hop
+	Findings        []Finding
+	BodyTruncated   bool
+}
+
+// CheckCSP returns the policies found in the CSP headers and in the
+// meta tags of the HTML body of a URL, along with their reporting
+// endpoints resolved using the Report-To and Reporting-Endpoints headers.
+// Redirects are followed manually to capture the policies of every hop.
+func CheckCSP(url string, client *http.Client, options RequestOptions) (CSPResponse, error) {
+	return CheckCSPContext(context.Background(), url, client, options)
+}
+
+// CheckCSPContext is CheckCSP with a context, cancelling the requests.
+func CheckCSPContext(ctx context.Context, url string, client *http.Client,
+	options RequestOptions) (CSPResponse, error) {
+	result := CSPResponse{
+		URL:             url,
+		Policies:        []Policy{},
+		ReportEndpoints: []ReportEndpoint{},
+		Hops:            []Hop{},
+		Findings:        []Finding{},
 	}
 
-	req.Header.Add("User-Agent", ua)
+	log := options.logger()
+	log.Debugf("Checking CSP for %s", url)
 
-	resp, err := client.Do(req)
+	resp, err := doRequest(ctx, url, client, options)
 	if err != nil {
 		return result, err
 	}
 
+	for {
+		next := nextHop(resp, len(result.Hops), options)
+		if next == nil {
+			break
+		}
+
+		result.extract(resp, nil, options)
+		result.Hops = append(result.Hops, Hop{
+			URL:        resp.Request.URL.String(),
+			StatusCode: resp.StatusCode,
+			Location:   next.String(),
+			Header:     resp.Header,
+			Policies:   headerPolicies(resp.Header),
+		})
+
+		log.Debugf("Following redirect %s -> %s (%d)", resp.Request.URL, next, resp.StatusCode)
+
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, options.BodyLimit))
+		resp.Body.Close()
+
+		if !strings.EqualFold(next.Hostname(), resp.Request.URL.Hostname()) {
+			options.Headers = withoutSensitiveHeaders(options.Headers)
+		}
+
+		if resp, err = doRequest(ctx, next.String(), client, options); err != nil {
+			return result, err
+		}
+	}
+
 	defer resp.Body.Close()
 
+	result.URL = resp.Request.URL.String()
+	result.StatusCode = resp.StatusCode
+	result.Header = resp.Header
+	result.Policies = headerPolicies(resp.Header)
+
+	readMeta := options.ForceBody || IsHTML(resp.Header.Get("Content-Type"))
+
+	switch {
+	case options.BodyLimit <= 0:
+		result.extract(resp, nil, options)
+	case slices.ContainsFunc(options.Extractors, needsBody):
+		// The body extractors need the whole body, so it's buffered.
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, options.BodyLimit))
+		if readMeta {
+			policies, _ := parseBodyPolicies(bytes.NewReader(body))
+			result.Policies = append(result.Policies, policies...)
+		}
+
+		result.BodyTruncated = int64(len(body)) == options.BodyLimit && bodyHasMore(resp.Body)
+		result.extract(resp, body, options)
+	case !readMeta:
+		log.Debugf("Skipping body of %s (%s)", result.URL, resp.Header.Get("Content-Type"))
+		result.extract(resp, nil, options)
+	default:
+		body := &countingReader{r: io.LimitReader(resp.Body, options.BodyLimit)}
+		policies, complete := parseBodyPolicies(body)
+		result.Policies = append(result.Policies, policies...)
+		result.BodyTruncated = !complete && body.n == options.BodyLimit && bodyHasMore(resp.Body)
+		result.extract(resp, nil, options)
+	}
+
+	if result.BodyTruncated {
+		log.Debugf("Body of %s truncated at %d KB", result.URL, options.BodyLimit/KB)
+	}
+
+	result.ReportEndpoints = ResolveEndpoints(
+		ReportEndpoints(result.Policies, ReportingGroups(resp.Header)),
+		resp.Request.URL,
+	)
+
+	return result, nil
+}
+
+// extract adds the findings of the extractors for the response,
+// as found at the current hop. body is nil for redirect responses.
+func (c *CSPResponse) extract(resp *http.Response, body []byte, options RequestOptions) {
+	if len(options.Extractors) == 0 {
+		return
+	}
+
+	in := &ExtractorInput{Request: resp.Request, Header: resp.Header, TLS: resp.TLS}
+	findings := runExtractors(options.Extractors, in, body, options.logger())
+	c.Findings = append(c.Findings, withHop(findings, len(c.Hops))...)
+}
+
+// doRequest performs a GET request to the URL.
+func doRequest(ctx context.Context, url string, client *http.Client, options RequestOptions) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range options.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", options.UserAgent)
+	}
+
+	return client.Do(req)
+}
+
+// nextHop returns the URL the response redirects to, nil if
+// the redirect must not be followed according to the options.
+func nextHop(resp *http.Response, hops int, options RequestOptions) *url.URL {
+	if options.Redirects == input.RedirectNone || hops >= options.MaxRedirects {
+		return nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+
+	next, err := resp.Location()
+	if err != nil {
+		return nil
+	}
+
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return nil
+	}
+
+	if options.Redirects == input.RedirectSameHost && !strings.EqualFold(next.Hostname(), resp.Request.URL.Hostname()) {
+		return nil
+	}
+
+	return next
+}
+
+// headerPolicies returns the policies delivered with the CSP headers.
+func headerPolicies(header http.Header) []Policy {
+	result := []Policy{}
 	cspHeaders := []string{
-		"Content-Security-Policy",
-		"Content-Security-Policy-Report-Only",
-		"X-Content-Security-Policy",
-		"X-WebKit-CSP",
+		HeaderCSP,
+		HeaderCSPReportOnly,
+		HeaderXCSP,
+		HeaderXWebKitCSP,
 	}
 
 	for _, h := range cspHeaders {
-		if val := resp.Header.Get(h); val != "" {
-			result = append(result, ParseCSP(val, rCSP)...)
+		for _, val := range header.Values(h) {
+			result = append(result, withOrigin(ParsePolicyList(val), h)...)
 		}
 	}
 
-	bodyCSP := ParseBodyCSP(resp.Body, rCSP)
-	result = append(result, bodyCSP...)
-
-	return result, nil
+	return result
 }
 
 // ParseCSP returns the list of domains parsed from a raw CSP (string).
 func ParseCSP(input string, r *regexp.Regexp) []string {
-	result := r.FindAllString(input, -1)
-	return golazy.RemoveDuplicateValues(result)
+	return PolicyDomains(ParsePolicyList(input), r)
 }
 
 // ParseBodyCSP returns the list of domains parsed from the CSP found in the meta tag
 // of the input HTML body.
 func ParseBodyCSP(body io.Reader, rCSP *regexp.Regexp) []string {
-	result := []string{}
+	return PolicyDomains(ParseBodyPolicies(body), rCSP)
+}
 
-	limitedReader := io.LimitReader(body, MaxKBBodyReader)
+// ParseBodyPolicies returns the policies found in the meta tags
+// of the input HTML body. The body is tokenized as a stream and
+// parsing stops as soon as the head is over, since browsers
+// ignore CSP meta tags found outside of it.
+func ParseBodyPolicies(body io.Reader) []Policy {
+	result, _ := parseBodyPolicies(io.LimitReader(body, MaxKBBodyReader))
 
-	doc, err := goquery.NewDocumentFromReader(limitedReader)
-	if err != nil {
-		// HARD FIX
-		// https://github.com/edoardottt/csprecon/issues/482
-		// with a simple print instead of fatal/panic
-		// we get a SIGSEGV in doc.Find
-		return []string{}
-	}
-
-	// Add the 'i' modifier to make http-equiv case-insensitive
-	doc.Find("meta[http-equiv='Content-Security-Policy' i]").Each(func(i int, s *goquery.Selection) {
-		contentCSP := s.AttrOr("content", "")
-		if contentCSP != "" {
-			result = append(result, ParseCSP(contentCSP, rCSP)...)
+	return result
+}
+
+// parseBodyPolicies returns the policies found in the meta tags of the
+// input HTML body and whether the end of the head was reached.
+func parseBodyPolicies(body io.Reader) ([]Policy, bool) {
+	result := []Policy{}
+	z := html.NewTokenizer(body)
+	// Text in these elements is not body content.
+	inText := false
+
+	for {
+		tt := z.Next()
+
+		switch tt {
+		case html.ErrorToken:
+			// io.EOF or malformed markup, either way we're done.
+			return result, false
+		case html.TextToken:
+			// A leading byte order mark is not content either.
+			text := bytes.TrimPrefix(z.Text(), []byte(byteOrderMark))
+			if !inText && len(bytes.TrimSpace(text)) != 0 {
+				return result, true
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+
+			switch atom.Lookup(name) {
+			case atom.Head:
+				return result, true
+			case atom.Title, atom.Script, atom.Style, atom.Noscript, atom.Template:
+				inText = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+
+			switch atom.Lookup(name) {
+			case atom.Meta:
+				// The content is a single policy, commas don't split it.
+				if contentCSP := metaCSP(z, hasAttr); contentCSP != "" {
+					policy := ParsePolicy(contentCSP)
+					policy.Origin = OriginMeta
+					result = append(result, policy)
+				}
+			case atom.Title, atom.Script, atom.Style, atom.Noscript, atom.Template:
+				inText = tt == html.StartTagToken
+			case atom.Html, atom.Head, atom.Base, atom.Link:
+			default:
+				// <body> or any other element starting the body.
+				return result, true
+			}
+		case html.CommentToken, html.DoctypeToken:
 		}
-	})
+	}
+}
 
-	return result
+// metaCSP returns the content of the meta tag being tokenized
+// if it delivers a Content-Security-Policy (case-insensitive).
+func metaCSP(z *html.Tokenizer, hasAttr bool) string {
+	isCSP, content := false, ""
+
+	for hasAttr {
+		var key, val []byte
+
+		key, val, hasAttr = z.TagAttr()
+
+		switch string(key) {
+		case "http-equiv":
+			isCSP = strings.EqualFold(strings.TrimSpace(string(val)), HeaderCSP)
+		case "content":
+			content = string(val)
+		}
+	}
+
+	if !isCSP {
+		return ""
+	}
+
+	return content
+}
+
+// IsHTML reports whether the Content-Type is HTML. An empty
+// Content-Type is considered HTML, since browsers sniff it.
+func IsHTML(contentType string) bool {
+	if strings.TrimSpace(contentType) == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// bodyHasMore reports whether there is still data to read in the body.
+func bodyHasMore(body io.Reader) bool {
+	n, _ := io.ReadFull(body, make([]byte, 1))
+
+	return n > 0
 }
 
-func customClient(options *input.Options) (*http.Client, error) {
+// withOrigin sets the origin of the policies.
+func withOrigin(policies []Policy, origin string) []Policy {
+	for i := range policies {
+		policies[i].Origin = origin
+	}
+
+	return policies
+}
+
+// NewClient returns the HTTP client shared by the workers of a Runner:
+// a single transport pools the connections to every target.
+func NewClient(options *input.Options) (*http.Client, error) {
 	transport := http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		Proxy:           http.ProxyFromEnvironment,
@@ -113,8 +434,9 @@ func customClient(options *input.Options) (*http.Client, error) {
 			KeepAlive: KeepAlive * time.Second,
 		}).DialContext,
 		TLSHandshakeTimeout: TLSHandshakeTimeout * time.Second,
-		MaxIdleConns:        MaxIdleConns,
-		MaxIdleConnsPerHost: MaxIdleConnsPerHost,
+		MaxIdleConns:        options.MaxIdleConns,
+		MaxIdleConnsPerHost: options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     options.MaxConnsPerHost,
 		IdleConnTimeout:     IdleConnTimeout * time.Second,
 	}
 
@@ -125,15 +447,24 @@ func customClient(options *input.Options) (*http.Client, error) {
 		}
 
 		transport.Proxy = http.ProxyURL(u)
-
-		if options.Verbose {
-			gologger.Debug().Msgf("Using Proxy %s", options.Proxy)
-		}
 	}
 
 	client := http.Client{
 		Transport: &transport,
 		Timeout:   time.Duration(options.Timeout) * time.Second,
+		// Redirects are followed by CheckCSP.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	if options.CookieFile != "" {
+		jar, err := LoadCookieFile(options.CookieFile)
+		if err != nil {
+			return nil, err
+		}
+
+		client.Jar = jar
 	}
 
 	return &client, nil
@@ -7,10 +7,18 @@ This repository is under MIT License https://github.com/edoardottt/csprecon/blob
 package csprecon_test
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 
 	"github.com/stretchr/testify/require"
 )
@@ -143,3 +151,399 @@ func TestParseBodyCSP(t *testing.T) {
 		})
 	}
 }
+
+func TestParseBodyPolicies(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "no head",
+			input: `<meta http-equiv="Content-Security-Policy" content="script-src a.example.com">`,
+			want:  []string{"script-src a.example.com"},
+		},
+		{
+			name: "case-insensitive http-equiv",
+			input: `<html><head><title>a <b> c</title>
+				<META HTTP-EQUIV="content-security-policy" CONTENT="script-src a.example.com">
+				<meta http-equiv="refresh" content="script-src b.example.com">
+				</head></html>`,
+			want: []string{"script-src a.example.com"},
+		},
+		{
+			name: "multiple meta tags",
+			input: `<head><meta http-equiv="Content-Security-Policy" content="script-src a.example.com; img-src b.example.com">
+				<script>var x = "<body>";</script>
+				<meta http-equiv="Content-Security-Policy" content="style-src c.example.com"></head>`,
+			want: []string{"script-src a.example.com; img-src b.example.com", "style-src c.example.com"},
+		},
+		{
+			// The content of a meta tag is a single policy, not a list.
+			name:  "comma in meta content",
+			input: `<meta http-equiv="Content-Security-Policy" content="script-src a.example.com, img-src b.example.com">`,
+			want:  []string{"script-src a.example.com,"},
+		},
+		{
+			name: "meta after head",
+			input: `<head><meta http-equiv="Content-Security-Policy" content="script-src a.example.com"></head>
+				<meta http-equiv="Content-Security-Policy" content="script-src b.example.com">`,
+			want: []string{"script-src a.example.com"},
+		},
+		{
+			name: "meta in body",
+			input: `<html><body><meta http-equiv="Content-Security-Policy" content="script-src a.example.com">
+				</body></html>`,
+			want: []string{},
+		},
+		{
+			name:  "meta after body content",
+			input: `hello<meta http-equiv="Content-Security-Policy" content="script-src a.example.com">`,
+			want:  []string{},
+		},
+		{
+			name: "byte order mark",
+			input: "\ufeff<!DOCTYPE html><html><head>" +
+				`<meta http-equiv=Content-Security-Policy content="script-src a.example.com"></head></html>`,
+			want: []string{"script-src a.example.com"},
+		},
+		{
+			name:  "malformed markup",
+			input: `<<head <meta http-equiv="Content-Security-Policy" content="script-src a.example.com"`,
+			want:  []string{},
+		},
+		{
+			name:  "binary content",
+			input: "\x00\xff\xfe<\x00meta\x89PNG\r\n\x1a\n",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, p := range csprecon.ParseBodyPolicies(strings.NewReader(tt.input)) {
+				require.Equal(t, csprecon.OriginMeta, p.Origin)
+
+				names := []string{}
+				for _, d := range p.Directives {
+					names = append(names, d.Name+" "+d.Sources[0].Expression)
+				}
+
+				got = append(got, strings.Join(names, "; "))
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// largeHTML returns a page with a CSP meta tag in the head
+// followed by a large body, as found on most scanned targets.
+func largeHTML() string {
+	var b strings.Builder
+
+	b.WriteString(`<!DOCTYPE html><html><head><title>benchmark</title>`)
+	b.WriteString(`<meta http-equiv="Content-Security-Policy" content="script-src 'self' cdn.example.com">`)
+	b.WriteString(`<link rel="stylesheet" href="/style.css"></head><body>`)
+
+	for b.Len() < 400*1024 {
+		b.WriteString(`<div class="item"><a href="https://example.com/page">link</a><p>Lorem ipsum dolor sit amet</p></div>`)
+	}
+
+	b.WriteString(`</body></html>`)
+
+	return b.String()
+}
+
+func BenchmarkParseBodyPolicies(b *testing.B) {
+	page := largeHTML()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		csprecon.ParseBodyPolicies(strings.NewReader(page))
+	}
+}
+
+// BenchmarkParseBodyPoliciesDOM is the baseline building
+// the whole document, as done before the streaming tokenizer.
+func BenchmarkParseBodyPoliciesDOM(b *testing.B) {
+	page := largeHTML()
+
+	b.ReportAllocs()
+
+	for b.Loop() {
+		doc, err := html.Parse(io.LimitReader(strings.NewReader(page), csprecon.MaxKBBodyReader))
+		require.NoError(b, err)
+
+		for n := range doc.Descendants() {
+			if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+				for _, a := range n.Attr {
+					if a.Key == "content" {
+						csprecon.ParsePolicyList(a.Val)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{contentType: "", want: true},
+		{contentType: "text/html", want: true},
+		{contentType: "text/HTML; charset=utf-8", want: true},
+		{contentType: "application/xhtml+xml", want: true},
+		{contentType: "application/json", want: false},
+		{contentType: "image/png", want: false},
+		{contentType: "text/html; charset=", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			require.Equal(t, tt.want, csprecon.IsHTML(tt.contentType))
+		})
+	}
+}
+
+func TestCheckCSPBody(t *testing.T) {
+	meta := `<meta http-equiv="Content-Security-Policy" content="script-src a.example.com">`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "img-src b.example.com")
+
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(meta))
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<head>" + strings.Repeat("<!-- padding -->", 1024) + meta + "</head>"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<head>" + meta + "</head><body>" + strings.Repeat("x", 4096) + "</body>"))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		path          string
+		bodyLimit     int64
+		forceBody     bool
+		wantPolicies  int
+		wantTruncated bool
+	}{
+		{name: "html", path: "/", bodyLimit: csprecon.MaxKBBodyReader, wantPolicies: 2},
+		{name: "headers only", path: "/", bodyLimit: 0, wantPolicies: 1},
+		{name: "body after head not truncated", path: "/", bodyLimit: 200, wantPolicies: 2},
+		{name: "non-HTML skipped", path: "/json", bodyLimit: csprecon.MaxKBBodyReader, wantPolicies: 1},
+		{name: "non-HTML forced", path: "/json", bodyLimit: csprecon.MaxKBBodyReader, forceBody: true, wantPolicies: 2},
+		{name: "truncated", path: "/large", bodyLimit: csprecon.KB, wantPolicies: 1, wantTruncated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := csprecon.CheckCSP(server.URL+tt.path, server.Client(), csprecon.RequestOptions{
+				UserAgent: "csprecon",
+				BodyLimit: tt.bodyLimit,
+				ForceBody: tt.forceBody,
+			})
+			require.NoError(t, err)
+			require.Len(t, got.Policies, tt.wantPolicies)
+			require.Equal(t, tt.wantTruncated, got.BodyTruncated)
+		})
+	}
+}
+
+func TestCheckCSPRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "script-src other.example.com")
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sso":
+			w.Header().Set("Content-Security-Policy", "script-src sso.example.com")
+			http.Redirect(w, r, "/edge", http.StatusFound)
+		case "/edge":
+			w.Header().Set("Content-Security-Policy-Report-Only", "script-src edge.example.com")
+			http.Redirect(w, r, "/app", http.StatusMovedPermanently)
+		case "/away":
+			// Same server, different host.
+			http.Redirect(w, r, strings.Replace(other.URL, "127.0.0.1", "localhost", 1)+"/", http.StatusTemporaryRedirect)
+		default:
+			w.Header().Set("Content-Security-Policy", "script-src app.example.com")
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		redirects    string
+		maxRedirects int
+		wantHops     []int
+		wantDomains  []string
+	}{
+		{
+			name:         "no redirects",
+			path:         "/sso",
+			redirects:    input.RedirectNone,
+			maxRedirects: 10,
+			wantHops:     []int{},
+			wantDomains:  []string{"sso.example.com@0"},
+		},
+		{
+			name:         "every hop",
+			path:         "/sso",
+			redirects:    input.RedirectAll,
+			maxRedirects: 10,
+			wantHops:     []int{http.StatusFound, http.StatusMovedPermanently},
+			wantDomains:  []string{"sso.example.com@0", "edge.example.com@1", "app.example.com@2"},
+		},
+		{
+			name:         "max redirects",
+			path:         "/sso",
+			redirects:    input.RedirectAll,
+			maxRedirects: 1,
+			wantHops:     []int{http.StatusFound},
+			wantDomains:  []string{"sso.example.com@0", "edge.example.com@1"},
+		},
+		{
+			name:         "same host",
+			path:         "/away",
+			redirects:    input.RedirectSameHost,
+			maxRedirects: 10,
+			wantHops:     []int{},
+			wantDomains:  []string{},
+		},
+		{
+			name:         "other host",
+			path:         "/away",
+			redirects:    input.RedirectAll,
+			maxRedirects: 10,
+			wantHops:     []int{http.StatusTemporaryRedirect},
+			wantDomains:  []string{"other.example.com@1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := server.Client()
+			client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+
+			got, err := csprecon.CheckCSP(server.URL+tt.path, client, csprecon.RequestOptions{
+				BodyLimit:    csprecon.MaxKBBodyReader,
+				Redirects:    tt.redirects,
+				MaxRedirects: tt.maxRedirects,
+			})
+			require.NoError(t, err)
+
+			hops := []int{}
+			for _, hop := range got.Hops {
+				hops = append(hops, hop.StatusCode)
+			}
+			require.Equal(t, tt.wantHops, hops)
+
+			domains := []string{}
+			for _, f := range csprecon.ResponseFindings(got, csprecon.CompileRegex(csprecon.DomainRegex)) {
+				domains = append(domains, fmt.Sprintf("%s@%d", f.Domain, f.Hop))
+			}
+			require.Equal(t, tt.wantDomains, domains)
+		})
+	}
+}
+
+const (
+	benchmarkTargets     = 2000
+	benchmarkConcurrency = 50
+)
+
+// checkTargets checks the CSP of every target with the given
+// concurrency, using the client returned by newClient.
+func checkTargets(b *testing.B, targets []string, newClient func() (*http.Client, func())) {
+	b.Helper()
+
+	jobs := make(chan string)
+	wg := sync.WaitGroup{}
+
+	for range benchmarkConcurrency {
+		wg.Go(func() {
+			for target := range jobs {
+				client, release := newClient()
+
+				if _, err := csprecon.CheckCSP(target, client, csprecon.RequestOptions{BodyLimit: csprecon.MaxKBBodyReader}); err != nil {
+					b.Error(err)
+				}
+
+				release()
+			}
+		})
+	}
+
+	for _, target := range targets {
+		jobs <- target
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+func benchmarkCheckCSP(b *testing.B, pooled bool) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", "script-src 'self' cdn.example.com")
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><head><title>benchmark</title></head><body></body></html>`))
+	}))
+	defer server.Close()
+
+	targets := []string{}
+	for i := range benchmarkTargets {
+		targets = append(targets, fmt.Sprintf("%s/target/%d", server.URL, i))
+	}
+
+	options := &input.Options{
+		Timeout:             input.DefaultTimeout,
+		MaxIdleConns:        input.DefaultIdleConns,
+		MaxIdleConnsPerHost: benchmarkConcurrency,
+	}
+
+	shared, err := csprecon.NewClient(options)
+	require.NoError(b, err)
+
+	defer shared.CloseIdleConnections()
+
+	newClient := func() (*http.Client, func()) {
+		if pooled {
+			return shared, func() {}
+		}
+
+		// A new transport for every target, idle connections
+		// are closed to avoid exhausting the file descriptors.
+		client, err := csprecon.NewClient(options)
+		require.NoError(b, err)
+
+		return client, client.CloseIdleConnections
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for b.Loop() {
+		checkTargets(b, targets, newClient)
+	}
+
+	b.ReportMetric(float64(b.N*benchmarkTargets)/b.Elapsed().Seconds(), "targets/s")
+}
+
+func BenchmarkCheckCSPPooledClient(b *testing.B) {
+	benchmarkCheckCSP(b, true)
+}
+
+// BenchmarkCheckCSPClientPerTarget is the baseline building
+// a new client for every target, as done before the shared pool.
+func BenchmarkCheckCSPClientPerTarget(b *testing.B) {
+	benchmarkCheckCSP(b, false)
+}
@@ -8,136 +8,329 @@ package csprecon
 
 import (
 	"bufio"
-	"fmt"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
-	"strings"
+	"regexp"
 	"sync"
 
 	"github.com/edoardottt/csprecon/pkg/input"
 	"github.com/edoardottt/csprecon/pkg/output"
 	"github.com/edoardottt/golazy"
-	"github.com/projectdiscovery/gologger"
-	fileutil "github.com/projectdiscovery/utils/file"
+	"go.uber.org/ratelimit"
 )
 
 const (
 	DefaultFilePermission = 0644
 )
 
+// Runner scans the targets of the options. Each result is passed to
+// OnResult, if set, and written to Options.Output, if set, as text,
+// JSON or graph. Logger receives the log messages. Run can be called
+// once per Runner.
 type Runner struct {
 	Input      chan string
 	Output     chan string
-	JSONOutput chan []string
+	JSONOutput chan output.JSONData
 	Result     output.Result
 	UserAgent  string
+	UserAgents []string
 	InWg       *sync.WaitGroup
 	OutWg      *sync.WaitGroup
 	Options    input.Options
 	OutMutex   *sync.Mutex
+	BypassDB   BypassDB
+	Client     *http.Client
+	Registry   *Registry
+	Graph      *Graph
+	Logger     Logger
+	OnResult   func(Result)
+	frontier   *frontier
+	resultMu   sync.Mutex
+	writeErr   error
 }
 
-func New(options *input.Options) Runner {
-	if options.FileOutput != "" {
-		_, err := os.Create(options.FileOutput)
+// New returns a Runner configured by the options.
+// It fails if the options are not valid or if a file
+// given by the options can't be loaded.
+func New(options *input.Options) (*Runner, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	bypassDB := DefaultBypassDB()
+
+	if options.BypassDB != "" {
+		db, err := LoadBypassDB(options.BypassDB)
+		if err != nil {
+			return nil, err
+		}
+
+		bypassDB = db
+	}
+
+	userAgent, userAgents := golazy.GenerateRandomUserAgent(), []string{}
+
+	if options.UserAgentList != "" {
+		list, err := LoadUserAgents(options.UserAgentList)
 		if err != nil {
-			gologger.Error().Msgf("%s", err)
+			return nil, err
 		}
+
+		userAgents = list
 	}
 
-	return Runner{
+	switch {
+	case options.UserAgent != "":
+		userAgent = options.UserAgent
+	case len(userAgents) != 0:
+		userAgent = RandomUserAgent(userAgents)
+	}
+
+	client, err := NewClient(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
 		Input:      make(chan string, options.Concurrency),
 		Output:     make(chan string, options.Concurrency),
-		JSONOutput: make(chan []string, options.Concurrency),
+		JSONOutput: make(chan output.JSONData, options.Concurrency),
 		Result:     output.New(),
-		UserAgent:  golazy.GenerateRandomUserAgent(),
+		UserAgent:  userAgent,
+		UserAgents: userAgents,
 		InWg:       &sync.WaitGroup{},
 		OutWg:      &sync.WaitGroup{},
 		Options:    *options,
 		OutMutex:   &sync.Mutex{},
-	}
+		BypassDB:   bypassDB,
+		Client:     client,
+		Registry:   NewRegistry(),
+		Graph:      NewGraph(),
+		Logger:     GologgerLogger{},
+		frontier:   newFrontier(),
+	}, nil
 }
 
-func (r *Runner) Run() {
+// Run scans every input until they are over or the context is done.
+// It returns the cause of the interruption (e.g. the context error,
+// an output write error), nil if every input was scanned.
+func (r *Runner) Run(ctx context.Context) error {
+	options, err := r.requestOptions()
+	if err != nil {
+		return err
+	}
+
+	lines := []string{}
+
+	if r.Options.FileInput != "" {
+		if lines, err = readLines(r.Options.FileInput); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	r.OutWg.Add(1)
 
-	go pullOutput(r)
+	go pullOutput(r, cancel)
 
 	r.InWg.Add(1)
 
-	go execute(r)
+	go execute(ctx, r, options)
 
-	r.InWg.Add(1)
+	// Input is closed once every input, including the ones found
+	// by the recursive discovery, has been scanned.
+	r.frontier.pending.Add(1)
+
+	go func() {
+		pushInput(ctx, r, lines)
+		r.frontier.pending.Done()
+	}()
 
-	go pushInput(r)
+	go func() {
+		r.frontier.pending.Wait()
+		close(r.Input)
+	}()
 
 	r.InWg.Wait()
+	r.Client.CloseIdleConnections()
+
+	if r.Options.Graph != "" && ctx.Err() == nil {
+		data := r.Graph.Data()
+
+		out, err := FormatGraph(&data, r.Options.Graph)
+		if err != nil {
+			cancel(err)
+		} else {
+			r.Output <- string(out)
+		}
+	}
 
 	close(r.Output)
 	close(r.JSONOutput)
 	r.OutWg.Wait()
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	return nil
 }
 
-func pushInput(r *Runner) {
-	defer r.InWg.Done()
+// requestOptions returns the options of the requests of the workers.
+func (r *Runner) requestOptions() (RequestOptions, error) {
+	headers, err := RequestHeaders(&r.Options)
+	if err != nil {
+		return RequestOptions{}, err
+	}
 
-	if fileutil.HasStdin() {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			if r.Options.Cidr {
-				ips, err := handleCIDRInput(scanner.Text())
-				if err != nil {
-					gologger.Error().Msg(err.Error())
-				} else {
-					for _, ip := range ips {
-						r.Input <- ip
-					}
-				}
-			} else {
-				r.Input <- scanner.Text()
+	extractors, err := r.Registry.Select(ExtractorNames(&r.Options))
+	if err != nil {
+		return RequestOptions{}, err
+	}
+
+	for name, values := range headers {
+		for _, value := range values {
+			r.Logger.Debugf("Using header %s: %s", name, RedactHeader(name, value))
+		}
+	}
+
+	if r.Options.Proxy != "" {
+		r.Logger.Debugf("Using Proxy %s", r.Options.Proxy)
+	}
+
+	if r.Options.CookieFile != "" {
+		r.Logger.Debugf("Using cookies from %s", r.Options.CookieFile)
+	}
+
+	if r.Options.CompareUserAgents {
+		for _, agent := range ListAgents(r.UserAgents) {
+			r.Logger.Debugf("Comparing User-Agent %s: %s", agent.Name, agent.UserAgent)
+		}
+	}
+
+	return RequestOptions{
+		UserAgent:    r.UserAgent,
+		Headers:      headers,
+		BodyLimit:    int64(r.Options.BodyLimit) * KB,
+		ForceBody:    r.Options.ForceBody,
+		Redirects:    r.Options.Redirects,
+		MaxRedirects: r.Options.MaxRedirects,
+		Extractors:   extractors,
+		Logger:       r.Logger,
+	}, nil
+}
+
+// readLines returns the non-empty lines of the file, deduplicated.
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := []string{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			result = append(result, line)
+		}
+	}
+
+	return golazy.RemoveDuplicateValues(result), scanner.Err()
+}
+
+// pushInput sends the inputs (stdin, file lines, input flag)
+// to the workers, expanding the CIDRs if requested.
+func pushInput(ctx context.Context, r *Runner, lines []string) {
+	push := func(value string) bool {
+		if !r.Options.Cidr {
+			return r.enqueue(ctx, value)
+		}
+
+		ips, err := handleCIDRInput(value)
+		if err != nil {
+			r.Logger.Errorf("%s", err)
+
+			return true
+		}
+
+		for _, ip := range ips {
+			if !r.enqueue(ctx, ip) {
+				return false
 			}
 		}
+
+		return true
 	}
 
-	if r.Options.FileInput != "" {
-		for _, line := range golazy.RemoveDuplicateValues(golazy.ReadFileLineByLine(r.Options.FileInput)) {
-			if r.Options.Cidr {
-				ips, err := handleCIDRInput(line)
-				if err != nil {
-					gologger.Error().Msg(err.Error())
-				} else {
-					for _, ip := range ips {
-						r.Input <- ip
-					}
+	if r.Options.Stdin != nil {
+		// Reading stdin blocks: the context is watched meanwhile.
+		stdin := scanLines(ctx, r.Options.Stdin)
+
+		for done := false; !done; {
+			select {
+			case line, ok := <-stdin:
+				done = !ok
+				if ok && !push(line) {
+					return
 				}
-			} else {
-				r.Input <- line
+			case <-ctx.Done():
+				return
 			}
 		}
 	}
 
+	for _, line := range lines {
+		if !push(line) {
+			return
+		}
+	}
+
 	if r.Options.Input != "" {
-		if r.Options.Cidr {
-			ips, err := handleCIDRInput(r.Options.Input)
-			if err != nil {
-				gologger.Error().Msg(err.Error())
-			} else {
-				for _, ip := range ips {
-					r.Input <- ip
-				}
+		push(r.Options.Input)
+	}
+}
+
+// scanLines sends the lines of the reader to the returned channel,
+// closed at the end of the reader. The scan stops sending when the
+// context is done.
+func scanLines(ctx context.Context, reader io.Reader) <-chan string {
+	result := make(chan string)
+
+	go func() {
+		defer close(result)
+
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			select {
+			case result <- scanner.Text():
+			case <-ctx.Done():
+				return
 			}
-		} else {
-			r.Input <- r.Options.Input
 		}
-	}
+	}()
 
-	close(r.Input)
+	return result
 }
 
-func execute(r *Runner) {
+func execute(ctx context.Context, r *Runner, options RequestOptions) {
 	defer r.InWg.Done()
 
 	dregex := CompileRegex(DomainRegex)
 	rl := rateLimiter(r)
+	probes := []input.Probe{}
+
+	for _, value := range r.Options.Probes {
+		if probe, err := input.ParseProbe(value); err == nil {
+			probes = append(probes, probe)
+		}
+	}
 
 	for i := 0; i < r.Options.Concurrency; i++ {
 		r.InWg.Add(1)
@@ -146,139 +339,227 @@ func execute(r *Runner) {
 			defer r.InWg.Done()
 
 			for value := range r.Input {
-				targetURL, err := PrepareURL(value)
-				if err != nil {
-					gologger.Error().Msgf("%s", err)
-
-					continue
+				// Once the context is done the inputs left are drained.
+				if ctx.Err() == nil {
+					r.process(ctx, value, probes, options, rl, dregex)
 				}
 
-				rl.Take()
+				r.frontier.pending.Done()
+			}
+		}()
+	}
+}
 
-				client, err := customClient(&r.Options)
-				if err != nil {
-					gologger.Error().Msgf("%s", err)
+// process scans the target URLs of the input.
+func (r *Runner) process(ctx context.Context, value string, probes []input.Probe, options RequestOptions,
+	rl ratelimit.Limiter, dregex *regexp.Regexp) {
+	targetURLs, err := ProbeURLs(value, probes)
+	if err != nil {
+		r.Logger.Errorf("%s", err)
 
-					continue
-				}
+		return
+	}
 
-				result, err := CheckCSP(targetURL, r.UserAgent, dregex, client)
-				if err != nil {
-					if r.Options.Verbose {
-						gologger.Error().Msgf("%s", err)
-					}
+	depth := r.frontier.depth(value)
 
-					continue
-				}
+	for _, targetURL := range targetURLs {
+		rl.Take()
 
-				if r.Options.JSON {
-					if len(r.Options.Domain) != 0 {
-						tempResult := []string{}
-
-						for _, res := range result {
-							if DomainOk(res, r.Options.Domain) {
-								tempResult = append(tempResult, res)
-							}
-						}
-
-						r.JSONOutput <- append(tempResult, targetURL)
-					} else {
-						r.JSONOutput <- append(result, targetURL)
-					}
-				} else {
-					for _, res := range result {
-						if resTrimmed := strings.TrimSpace(res); resTrimmed != "" {
-							if len(r.Options.Domain) != 0 {
-								if DomainOk(resTrimmed, r.Options.Domain) {
-									r.Output <- resTrimmed
-								}
-							} else {
-								r.Output <- resTrimmed
-							}
-						}
-					}
-				}
-			}
-		}()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if r.scan(ctx, targetURL, options, dregex, depth) && r.Options.ProbeFirst {
+			return
+		}
 	}
 }
 
-func pullOutput(r *Runner) {
-	defer r.OutWg.Done()
-
-	if r.Options.JSON {
-		for o := range r.JSONOutput {
-			r.OutWg.Add(1)
+// scan checks the CSP of the target URL, found at the given depth, and
+// sends the results to the output. It returns false if the target didn't respond.
+func (r *Runner) scan(ctx context.Context, targetURL string, options RequestOptions,
+	dregex *regexp.Regexp, depth int) bool {
+	var (
+		resp     CSPResponse
+		findings []Finding
+		diffs    []PolicyDifference
+		err      error
+	)
+
+	if r.Options.RotateUserAgent {
+		options.UserAgent = RandomUserAgent(r.UserAgents)
+	}
 
-			go writeJSONOutput(r.OutWg, r.OutMutex, &r.Options, o)
-		}
+	if r.Options.CompareUserAgents {
+		resp, findings, diffs, err = r.compareUserAgents(ctx, targetURL, options, dregex)
 	} else {
-		for o := range r.Output {
-			if !r.Result.Printed(o) {
-				r.OutWg.Add(1)
+		resp, err = CheckCSPContext(ctx, targetURL, r.Client, options)
+		findings = ResponseFindings(resp, dregex)
+	}
 
-				go writeOutput(r.OutWg, r.OutMutex, &r.Options, o)
-			}
+	if err != nil {
+		if r.Options.Verbose && ctx.Err() == nil {
+			r.Logger.Errorf("%s", err)
 		}
+
+		return false
 	}
+
+	res := Result{
+		URL:           targetURL,
+		Policies:      resp.Policies,
+		Findings:      findings,
+		UADiffs:       diffs,
+		Hops:          resp.Hops,
+		FinalURL:      resp.URL,
+		StatusCode:    resp.StatusCode,
+		BodyTruncated: resp.BodyTruncated,
+		Depth:         depth,
+	}
+
+	if len(r.Options.Domain) != 0 {
+		res.Findings = FilterFindings(res.Findings, r.Options.Domain)
+	}
+
+	if len(r.Options.Origin) != 0 {
+		res.Findings = FilterFindingsByOrigin(res.Findings, r.Options.Origin)
+	}
+
+	r.discover(ctx, res.Findings, targetURL, depth)
+
+	res.Findings = WildcardFindings(res.Findings, r.Options.Wildcard)
+
+	if r.Options.Analyze {
+		res.Weaknesses = EvaluateCSP(resp.Policies)
+		res.Bypasses = FindBypasses(resp.Policies, r.BypassDB)
+	}
+
+	if r.Options.ReportEndpoints {
+		res.ReportEndpoints = filterEndpoints(resp.ReportEndpoints, r.Options.Domain, r.Options.Origin)
+	}
+
+	if r.Options.Effective {
+		// 'self' is the origin of the final response.
+		self, _ := url.Parse(resp.URL)
+		effective := ComputeEffectivePolicy(resp.Policies, self)
+		res.Effective = &effective
+	}
+
+	r.emit(&res)
+
+	return true
 }
 
-func writeOutput(wg *sync.WaitGroup, m *sync.Mutex, options *input.Options, o string) {
-	defer wg.Done()
+// emit passes the result to the callback and sends it to the output.
+func (r *Runner) emit(res *Result) {
+	if r.OnResult != nil {
+		r.resultMu.Lock()
+		r.OnResult(*res)
+		r.resultMu.Unlock()
+	}
 
-	if options.FileOutput != "" && options.Output == nil {
-		file, err := os.OpenFile(options.FileOutput, os.O_CREATE|os.O_RDWR|os.O_APPEND, DefaultFilePermission)
-		if err != nil {
-			gologger.Fatal().Msg(err.Error())
+	switch {
+	case r.Options.Graph != "":
+		r.Graph.Add(res.URL, res.Depth, res.Findings)
+	case r.Options.JSON:
+		r.JSONOutput <- jsonData(res, &r.Options)
+	default:
+		for _, line := range textLines(res, &r.Options) {
+			r.Output <- line
 		}
+	}
+}
 
-		options.Output = file
+// compareUserAgents checks the CSP of the target URL with every agent
+// to compare. It returns the response of the first agent responding,
+// the findings of all of them and the differences between the policies.
+func (r *Runner) compareUserAgents(ctx context.Context, targetURL string, options RequestOptions,
+	dregex *regexp.Regexp) (CSPResponse, []Finding, []PolicyDifference, error) {
+	agents := DefaultCompareAgents()
+
+	if len(r.UserAgents) != 0 {
+		agents = ListAgents(r.UserAgents)
 	}
 
-	m.Lock()
+	var (
+		result   CSPResponse
+		err      error
+		names    = []string{}
+		policies = [][]Policy{}
+		findings = []Finding{}
+	)
+
+	for _, agent := range agents {
+		options.UserAgent = agent.UserAgent
+
+		resp, checkErr := CheckCSPContext(ctx, targetURL, r.Client, options)
+		if checkErr != nil {
+			err = checkErr
 
-	if options.Output != nil {
-		if _, err := options.Output.Write([]byte(o + "\n")); err != nil && options.Verbose {
-			gologger.Fatal().Msg(err.Error())
+			r.Logger.Debugf("%s (%s): %s", targetURL, agent.Name, checkErr)
+
+			continue
 		}
+
+		if len(names) == 0 {
+			result = resp
+		}
+
+		names = append(names, agent.Name)
+		policies = append(policies, resp.Policies)
+		findings = append(findings, ResponseFindings(resp, dregex)...)
 	}
 
-	m.Unlock()
+	if len(names) == 0 {
+		return result, nil, nil, err
+	}
 
-	fmt.Println(o)
+	return result, golazy.RemoveDuplicateValues(findings), ComparePolicies(names, policies), nil
 }
 
-func writeJSONOutput(wg *sync.WaitGroup, m *sync.Mutex, options *input.Options, o []string) {
-	defer wg.Done()
+// pullOutput writes the results to the output. After the first write
+// error the run is cancelled and the results left are discarded.
+func pullOutput(r *Runner, cancel context.CancelCauseFunc) {
+	defer r.OutWg.Done()
 
-	if options.FileOutput != "" && options.Output == nil {
-		file, err := os.OpenFile(options.FileOutput, os.O_CREATE|os.O_RDWR|os.O_APPEND, DefaultFilePermission)
-		if err != nil {
-			gologger.Fatal().Msg(err.Error())
+	if r.Options.JSON {
+		for o := range r.JSONOutput {
+			out, err := output.FormatJSONData(&o)
+			if err == nil {
+				err = r.write(out)
+			}
+
+			if err != nil {
+				cancel(err)
+			}
 		}
 
-		options.Output = file
+		return
 	}
 
-	url, result, err := output.PrepareJSONOutput(o)
-	if err != nil {
-		gologger.Fatal().Msg(err.Error())
+	for o := range r.Output {
+		if !r.Result.Printed(o) {
+			if err := r.write([]byte(o)); err != nil {
+				cancel(err)
+			}
+		}
 	}
+}
 
-	out, err := output.FormatJSON(url, result)
-	if err != nil {
-		gologger.Fatal().Msg(err.Error())
+// write writes a line to the output, nothing after the first error.
+func (r *Runner) write(line []byte) error {
+	r.OutMutex.Lock()
+	defer r.OutMutex.Unlock()
+
+	if r.Options.Output == nil || r.writeErr != nil {
+		return nil
 	}
 
-	m.Lock()
+	if _, err := r.Options.Output.Write(append(line, '\n')); err != nil {
+		r.writeErr = err
 
-	if options.Output != nil {
-		if _, err := options.Output.Write(append(out, byte('\n'))); err != nil && options.Verbose {
-			gologger.Fatal().Msg(err.Error())
-		}
+		return err
 	}
 
-	m.Unlock()
-
-	fmt.Println(string(out))
+	return nil
 }
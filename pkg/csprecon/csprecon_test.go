@@ -0,0 +1,184 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+
+	"github.com/stretchr/testify/require"
+)
+
+var errWrite = errors.New("write failed")
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func testOptions(output *bytes.Buffer) *input.Options {
+	options := input.DefaultOptions()
+	options.Stdin = strings.NewReader("a.test\nb.test\n")
+	options.Concurrency = 2
+
+	if output != nil {
+		options.Output = output
+	}
+
+	return options
+}
+
+func testServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(csprecon.HeaderCSP, "script-src cdn."+r.Host)
+	}))
+}
+
+func TestRunOnResult(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+
+	runner := newTestRunner(t, testOptions(nil), server)
+	runner.Logger = csprecon.NopLogger{}
+
+	got := []string{}
+	runner.OnResult = func(res csprecon.Result) {
+		got = append(got, res.URL+" "+strings.Join(csprecon.FindingsDomains(res.Findings), ","))
+	}
+
+	require.NoError(t, runner.Run(context.Background()))
+
+	sort.Strings(got)
+	require.Equal(t, []string{"http://a.test cdn.a.test", "http://b.test cdn.b.test"}, got)
+}
+
+func TestRunOutput(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	runner := newTestRunner(t, testOptions(out), server)
+	require.NoError(t, runner.Run(context.Background()))
+
+	got := strings.Fields(out.String())
+	sort.Strings(got)
+	require.Equal(t, []string{"cdn.a.test", "cdn.b.test"}, got)
+
+	options := testOptions(nil)
+	options.Output = failingWriter{}
+	runner = newTestRunner(t, options, server)
+	require.ErrorIs(t, runner.Run(context.Background()), errWrite)
+}
+
+func TestRunCanceled(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := newTestRunner(t, testOptions(nil), server)
+	runner.OnResult = func(res csprecon.Result) {
+		t.Errorf("unexpected result %s", res.URL)
+	}
+
+	require.ErrorIs(t, runner.Run(ctx), context.Canceled)
+
+	// Stdin still open: Run returns at the deadline anyway.
+	stdin, writer := io.Pipe()
+	defer writer.Close()
+
+	options := testOptions(nil)
+	options.Stdin = stdin
+
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	runner = newTestRunner(t, options, server)
+
+	done := make(chan error)
+
+	go func() {
+		done <- runner.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the deadline")
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		options func(*input.Options)
+		want    error
+	}{
+		{
+			name:    "no input",
+			options: func(o *input.Options) { o.Input = "" },
+			want:    input.ErrNoInput,
+		},
+		{
+			name:    "zero concurrency",
+			options: func(o *input.Options) { o.Concurrency = 0 },
+			want:    input.ErrNegativeValue,
+		},
+		{
+			name: "graph and json",
+			options: func(o *input.Options) {
+				o.Graph = input.GraphJSON
+				o.JSON = true
+			},
+			want: input.ErrMutexFlags,
+		},
+		{
+			name:    "header name with spaces",
+			options: func(o *input.Options) { o.Headers = []string{"X Foo: bar"} },
+			want:    input.ErrInvalidValue,
+		},
+		{
+			name:    "missing bypass dataset",
+			options: func(o *input.Options) { o.BypassDB = "missing.json" },
+			want:    input.ErrFileNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := input.DefaultOptions()
+			options.Input = "a.test"
+			tt.options(options)
+
+			_, err := csprecon.New(options)
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+
+	options := input.DefaultOptions()
+	options.Input = "a.test"
+	options.Extractors = []string{"missing"}
+
+	runner, err := csprecon.New(options)
+	require.NoError(t, err)
+	require.ErrorIs(t, runner.Run(context.Background()), input.ErrInvalidValue)
+}
@@ -0,0 +1,307 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"net/url"
+	"strings"
+)
+
+// nolint: gochecknoglobals
+var (
+	// EffectiveDirectives are the directives computed by ComputeEffectivePolicy,
+	// each one with its fallback list (CSP Level 3, section 6.8.3).
+	// base-uri, form-action and frame-ancestors don't fall back to anything.
+	EffectiveDirectives = []string{
+		"script-src",
+		"script-src-elem",
+		"script-src-attr",
+		"style-src",
+		"style-src-elem",
+		"style-src-attr",
+		"worker-src",
+		"connect-src",
+		"img-src",
+		"font-src",
+		"media-src",
+		"object-src",
+		"frame-src",
+		"child-src",
+		"manifest-src",
+		"base-uri",
+		"form-action",
+		"frame-ancestors",
+	}
+	fallbackLists = map[string][]string{
+		"script-src":      {"script-src", "default-src"},
+		"script-src-elem": {"script-src-elem", "script-src", "default-src"},
+		"script-src-attr": {"script-src-attr", "script-src", "default-src"},
+		"style-src":       {"style-src", "default-src"},
+		"style-src-elem":  {"style-src-elem", "style-src", "default-src"},
+		"style-src-attr":  {"style-src-attr", "style-src", "default-src"},
+		"worker-src":      {"worker-src", "child-src", "script-src", "default-src"},
+		"connect-src":     {"connect-src", "default-src"},
+		"img-src":         {"img-src", "default-src"},
+		"font-src":        {"font-src", "default-src"},
+		"media-src":       {"media-src", "default-src"},
+		"object-src":      {"object-src", "default-src"},
+		"frame-src":       {"frame-src", "child-src", "default-src"},
+		"child-src":       {"child-src", "default-src"},
+		"manifest-src":    {"manifest-src", "default-src"},
+		"base-uri":        {"base-uri"},
+		"form-action":     {"form-action"},
+		"frame-ancestors": {"frame-ancestors"},
+	}
+	// directives ignored by browsers when delivered with a meta tag.
+	metaIgnoredDirectives = map[string]struct{}{
+		"frame-ancestors": {},
+		"report-uri":      {},
+		"sandbox":         {},
+	}
+)
+
+// EffectiveSource is a source expression allowed by every policy,
+// along with the directive and the origin it was taken from.
+type EffectiveSource struct {
+	Source    Source
+	Directive string
+	Origin    string
+}
+
+// EffectiveDirective is the effective allowlist of a directive.
+// Unrestricted is true when no policy governs the directive, an empty
+// Sources list with Unrestricted false means nothing can be loaded.
+type EffectiveDirective struct {
+	Name         string
+	Unrestricted bool
+	Sources      []EffectiveSource
+}
+
+// EffectivePolicy is the result of combining all the policies of
+// a response, computed separately for enforced and report-only policies.
+type EffectivePolicy struct {
+	Enforced   []EffectiveDirective
+	ReportOnly []EffectiveDirective
+}
+
+// ComputeEffectivePolicy combines the policies of a response as browsers do:
+// a resource is loaded only if every policy allows it, and directives
+// missing from a policy are governed by their fallback directive.
+// 'self' is resolved against the URL of the response, if not nil.
+func ComputeEffectivePolicy(policies []Policy, self *url.URL) EffectivePolicy {
+	enforced, reportOnly := []Policy{}, []Policy{}
+
+	for _, policy := range policies {
+		if policy.ReportOnly() {
+			reportOnly = append(reportOnly, policy)
+		} else {
+			enforced = append(enforced, policy)
+		}
+	}
+
+	return EffectivePolicy{
+		Enforced:   effectiveDirectives(enforced, self),
+		ReportOnly: effectiveDirectives(reportOnly, self),
+	}
+}
+
+// Get returns the effective directive with the given name, if computed.
+func (e EffectivePolicy) Get(name string, reportOnly bool) (EffectiveDirective, bool) {
+	directives := e.Enforced
+	if reportOnly {
+		directives = e.ReportOnly
+	}
+
+	for _, d := range directives {
+		if d.Name == name {
+			return d, true
+		}
+	}
+
+	return EffectiveDirective{}, false
+}
+
+func effectiveDirectives(policies []Policy, self *url.URL) []EffectiveDirective {
+	result := []EffectiveDirective{}
+
+	for _, name := range EffectiveDirectives {
+		result = append(result, effectiveDirective(name, policies, self))
+	}
+
+	return result
+}
+
+// effectiveDirective computes the allowlist of a directive: a source is
+// kept only if every other policy governing the directive allows it.
+func effectiveDirective(name string, policies []Policy, self *url.URL) EffectiveDirective {
+	governing := []Directive{}
+	origins := []string{}
+
+	for _, policy := range policies {
+		if d, ok := governingDirective(policy, name); ok {
+			governing = append(governing, d)
+			origins = append(origins, policy.Origin)
+		}
+	}
+
+	result := EffectiveDirective{Name: name, Unrestricted: len(governing) == 0, Sources: []EffectiveSource{}}
+	seen := map[string]struct{}{}
+
+	for i, d := range governing {
+		for _, s := range d.Sources {
+			if _, ok := seen[s.Expression]; ok {
+				continue
+			}
+
+			allowed := true
+
+			for j, other := range governing {
+				if i != j && !listAllows(other.Sources, s, self) {
+					allowed = false
+
+					break
+				}
+			}
+
+			if allowed {
+				seen[s.Expression] = struct{}{}
+				result.Sources = append(result.Sources, EffectiveSource{Source: s, Directive: d.Name, Origin: origins[i]})
+			}
+		}
+	}
+
+	return result
+}
+
+// governingDirective returns the first directive of the fallback
+// list of name defined in the policy.
+func governingDirective(policy Policy, name string) (Directive, bool) {
+	for _, candidate := range fallbackLists[name] {
+		if _, ignored := metaIgnoredDirectives[candidate]; ignored && policy.Origin == OriginMeta {
+			continue
+		}
+
+		if d, ok := policy.Get(candidate); ok {
+			return d, true
+		}
+	}
+
+	return Directive{}, false
+}
+
+// listAllows reports whether the source list allows
+// everything allowed by the source s.
+func listAllows(list []Source, s Source, self *url.URL) bool {
+	for _, l := range list {
+		if sourceCovers(l, s, self) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sourceCovers reports whether the source l allows everything allowed
+// by the source s. It's an approximation of the CSP matching algorithm
+// working on source expressions instead of URLs. 'self' is the origin
+// of self, when unknown it's assumed to be an http or https origin.
+func sourceCovers(l, s Source, self *url.URL) bool {
+	if isSelf(l) && isSelf(s) {
+		return true
+	}
+
+	if isSelf(s) {
+		if self != nil {
+			return sourceCovers(l, selfSource(self), self)
+		}
+
+		return (l.Type == SourceHost && l.Host == "*" && l.Scheme == "" && l.Path == "") ||
+			(l.Type == SourceScheme && (l.Scheme == "http" || l.Scheme == "https"))
+	}
+
+	if isSelf(l) {
+		return self != nil && sourceCovers(selfSource(self), s, self)
+	}
+
+	switch l.Type {
+	case SourceKeyword, SourceNonce, SourceHash:
+		return s.Type == l.Type && s.Value == l.Value && s.Algorithm == l.Algorithm
+	case SourceScheme:
+		switch s.Type {
+		case SourceScheme:
+			return schemeCovers(l.Scheme, s.Scheme)
+		case SourceHost:
+			return s.Scheme == "" || schemeCovers(l.Scheme, s.Scheme)
+		case SourceOther, SourceKeyword, SourceNonce, SourceHash:
+		}
+	case SourceHost:
+		if s.Type != SourceHost {
+			return false
+		}
+
+		if l.Scheme != "" && s.Scheme != "" && !schemeCovers(l.Scheme, s.Scheme) {
+			return false
+		}
+
+		if l.Port != "" && l.Port != "*" && l.Port != s.Port {
+			return false
+		}
+
+		return hostCovers(l.Host, s.Host) && pathCovers(l.Path, s.Path)
+	case SourceOther:
+	}
+
+	return false
+}
+
+// isSelf reports whether the source is the 'self' keyword.
+func isSelf(s Source) bool {
+	return s.Type == SourceKeyword && s.Value == "self"
+}
+
+// selfSource returns the host source matching the origin of the URL.
+func selfSource(self *url.URL) Source {
+	return Source{
+		Expression: self.Scheme + "://" + self.Host,
+		Type:       SourceHost,
+		Scheme:     strings.ToLower(self.Scheme),
+		Host:       strings.ToLower(self.Hostname()),
+		Port:       self.Port(),
+	}
+}
+
+// schemeCovers reports whether the scheme l allows the scheme s,
+// taking into account the secure upgrades allowed by CSP.
+func schemeCovers(l, s string) bool {
+	return l == s || (l == "http" && s == "https") || (l == "ws" && s == "wss")
+}
+
+// hostCovers reports whether the host part l allows the host part s.
+func hostCovers(l, s string) bool {
+	if l == "*" || l == s {
+		return true
+	}
+
+	if base, ok := strings.CutPrefix(l, "*."); ok {
+		return strings.HasSuffix(s, "."+base)
+	}
+
+	return false
+}
+
+// pathCovers reports whether the path l allows the path s.
+func pathCovers(l, s string) bool {
+	if l == "" || l == "/" {
+		return true
+	}
+
+	if strings.HasSuffix(l, "/") {
+		return strings.HasPrefix(s, l)
+	}
+
+	return l == s
+}
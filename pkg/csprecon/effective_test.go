@@ -0,0 +1,155 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func policiesWithOrigin(origin, input string) []csprecon.Policy {
+	policies := csprecon.ParsePolicyList(input)
+	for i := range policies {
+		policies[i].Origin = origin
+	}
+
+	return policies
+}
+
+func effectiveExpressions(d csprecon.EffectiveDirective) []string {
+	result := []string{}
+	for _, s := range d.Sources {
+		result = append(result, s.Source.Expression)
+	}
+
+	return result
+}
+
+func TestComputeEffectivePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policies     []csprecon.Policy
+		self         string
+		directive    string
+		reportOnly   bool
+		unrestricted bool
+		want         []string
+	}{
+		{
+			name:         "no policies",
+			policies:     []csprecon.Policy{},
+			directive:    "script-src",
+			unrestricted: true,
+			want:         []string{},
+		},
+		{
+			name:      "default-src fallback",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "default-src 'self' cdn.example.com; img-src *"),
+			directive: "script-src-elem",
+			want:      []string{"'self'", "cdn.example.com"},
+		},
+		{
+			name:      "worker-src fallback to child-src",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "default-src 'none'; script-src a.example.com; child-src blob:"),
+			directive: "worker-src",
+			want:      []string{"blob:"},
+		},
+		{
+			name: "header and meta intersection",
+			policies: append(
+				policiesWithOrigin(csprecon.HeaderCSP, "script-src 'self' https://*.example.com https://other.com"),
+				policiesWithOrigin(csprecon.OriginMeta, "default-src 'self' https://cdn.example.com https://www.example.com/js/")...,
+			),
+			directive: "script-src",
+			want:      []string{"'self'", "https://cdn.example.com", "https://www.example.com/js/"},
+		},
+		{
+			name: "comma joined policies",
+			policies: policiesWithOrigin(csprecon.HeaderCSP,
+				"script-src https: 'unsafe-inline', script-src 'nonce-abc' cdn.example.com http://insecure.example.com"),
+			directive: "script-src",
+			want:      []string{"cdn.example.com"},
+		},
+		{
+			name: "report-only kept apart",
+			policies: append(
+				policiesWithOrigin(csprecon.HeaderCSP, "script-src *"),
+				policiesWithOrigin(csprecon.HeaderCSPReportOnly, "script-src 'self'")...,
+			),
+			directive:  "script-src",
+			reportOnly: true,
+			want:       []string{"'self'"},
+		},
+		{
+			name:         "frame-ancestors ignored in meta",
+			policies:     policiesWithOrigin(csprecon.OriginMeta, "frame-ancestors 'none'"),
+			directive:    "frame-ancestors",
+			unrestricted: true,
+			want:         []string{},
+		},
+		{
+			name: "wildcard covers 'self'",
+			policies: append(
+				policiesWithOrigin(csprecon.HeaderCSP, "script-src *"),
+				policiesWithOrigin(csprecon.OriginMeta, "script-src 'self'")...,
+			),
+			directive: "script-src",
+			want:      []string{"'self'"},
+		},
+		{
+			name:      "scheme covers 'self'",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "default-src https:, script-src 'self' 'unsafe-inline'"),
+			directive: "script-src",
+			want:      []string{"'self'"},
+		},
+		{
+			name:      "scheme not matching the response URL",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "default-src https:, script-src 'self'"),
+			self:      "http://www.example.com/login",
+			directive: "script-src",
+			want:      []string{},
+		},
+		{
+			name:      "host covers 'self'",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "script-src *.example.com, script-src 'self' other.com"),
+			self:      "https://www.example.com/login",
+			directive: "script-src",
+			want:      []string{"'self'"},
+		},
+		{
+			name:      "'self' covers the host of the response URL",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "script-src 'self', script-src https://www.example.com/js/ cdn.com"),
+			self:      "https://www.example.com/login",
+			directive: "script-src",
+			want:      []string{"https://www.example.com/js/"},
+		},
+		{
+			name:      "nothing allowed",
+			policies:  policiesWithOrigin(csprecon.HeaderCSP, "script-src a.example.com, script-src b.example.com"),
+			directive: "script-src",
+			want:      []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var self *url.URL
+			if tt.self != "" {
+				self, _ = url.Parse(tt.self)
+			}
+
+			effective := csprecon.ComputeEffectivePolicy(tt.policies, self)
+			got, ok := effective.Get(tt.directive, tt.reportOnly)
+			require.True(t, ok)
+			require.Equal(t, tt.unrestricted, got.Unrestricted)
+			require.Equal(t, tt.want, effectiveExpressions(got))
+		})
+	}
+}
@@ -0,0 +1,265 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity is the severity of a CSP weakness.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+)
+
+// nolint: gochecknoglobals
+var severityNames = map[Severity]string{
+	SeverityInfo:   "info",
+	SeverityLow:    "low",
+	SeverityMedium: "medium",
+	SeverityHigh:   "high",
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+
+	return severityNames[SeverityInfo]
+}
+
+const (
+	RuleUnsafeInline         = "script-unsafe-inline"
+	RuleUnsafeEval           = "script-unsafe-eval"
+	RuleScriptWildcard       = "script-wildcard"
+	RuleScriptScheme         = "script-scheme-source"
+	RuleScriptDataURI        = "script-data-uri"
+	RuleMissingScriptSrc     = "missing-script-src"
+	RuleMissingObjectSrc     = "missing-object-src"
+	RuleMissingBaseURI       = "missing-base-uri"
+	RuleMissingFrameAncestor = "missing-frame-ancestors"
+	RuleReportOnly           = "report-only"
+	RuleAllowlistBypass      = "script-allowlist-bypass"
+)
+
+// Weakness is a single issue found evaluating a policy.
+type Weakness struct {
+	RuleID      string
+	Severity    Severity
+	Directive   string
+	Origin      string
+	Description string
+}
+
+// String returns the weakness as a single line.
+func (w Weakness) String() string {
+	result := fmt.Sprintf("[%s] %s", w.Severity, w.RuleID)
+
+	if w.Directive != "" {
+		result += " " + w.Directive
+	}
+
+	if w.Origin != "" {
+		result += " (" + w.Origin + ")"
+	}
+
+	return result + ": " + w.Description
+}
+
+// EvaluateCSP evaluates the policies delivered with a response and
+// returns the weaknesses found, ranked by severity.
+func EvaluateCSP(policies []Policy) []Weakness {
+	result := []Weakness{}
+
+	if len(policies) == 0 {
+		return result
+	}
+
+	enforced, reportOnly := []Policy{}, []Policy{}
+	frameAncestors := false
+
+	for _, policy := range policies {
+		result = append(result, evaluatePolicy(policy)...)
+
+		if policy.ReportOnly() {
+			reportOnly = append(reportOnly, policy)
+
+			continue
+		}
+
+		enforced = append(enforced, policy)
+
+		// frame-ancestors is ignored when delivered with a meta tag.
+		if _, ok := policy.Get("frame-ancestors"); ok && policy.Origin != OriginMeta {
+			frameAncestors = true
+		}
+	}
+
+	// Browsers enforce all the policies together, a directive is
+	// missing only if no policy governs it.
+	switch {
+	case len(enforced) == 0:
+		result = append(result, evaluateMissing(reportOnly)...)
+		result = append(result, Weakness{
+			RuleID:      RuleReportOnly,
+			Severity:    SeverityMedium,
+			Origin:      HeaderCSPReportOnly,
+			Description: "policies are only deployed in report-only mode and are not enforced",
+		})
+	default:
+		result = append(result, evaluateMissing(enforced)...)
+
+		if !frameAncestors {
+			result = append(result, Weakness{
+				RuleID:      RuleMissingFrameAncestor,
+				Severity:    SeverityLow,
+				Directive:   "frame-ancestors",
+				Description: "no enforced header policy restricts framing, the page may be vulnerable to clickjacking",
+			})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Severity > result[j].Severity
+	})
+
+	return result
+}
+
+// evaluateMissing returns the weaknesses of the directives
+// missing from the combination of the policies.
+func evaluateMissing(policies []Policy) []Weakness {
+	result := []Weakness{}
+
+	if effectiveDirective("script-src", policies, nil).Unrestricted {
+		result = append(result, Weakness{
+			RuleID:      RuleMissingScriptSrc,
+			Severity:    SeverityHigh,
+			Directive:   "script-src",
+			Description: "neither script-src nor default-src are defined, scripts can be loaded from anywhere",
+		})
+	}
+
+	objectSrc := false
+
+	for _, policy := range policies {
+		if _, ok := policy.Get("object-src"); ok {
+			objectSrc = true
+		}
+	}
+
+	if !objectSrc && !allowsNothing(effectiveDirective("object-src", policies, nil)) {
+		result = append(result, Weakness{
+			RuleID:      RuleMissingObjectSrc,
+			Severity:    SeverityHigh,
+			Directive:   "object-src",
+			Description: "object-src is missing and default-src is not 'none', plugins can be used to execute scripts",
+		})
+	}
+
+	if effectiveDirective("base-uri", policies, nil).Unrestricted {
+		result = append(result, Weakness{
+			RuleID:      RuleMissingBaseURI,
+			Severity:    SeverityMedium,
+			Directive:   "base-uri",
+			Description: "base-uri is missing, an injected <base> tag can change where relative scripts are loaded from",
+		})
+	}
+
+	return result
+}
+
+// allowsNothing reports whether the effective directive blocks every load.
+func allowsNothing(d EffectiveDirective) bool {
+	if d.Unrestricted {
+		return false
+	}
+
+	for _, s := range d.Sources {
+		if s.Source.Type != SourceKeyword || s.Source.Value != "none" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// evaluatePolicy returns the weaknesses of the sources of a single policy.
+func evaluatePolicy(policy Policy) []Weakness {
+	result := []Weakness{}
+	weakness := func(rule string, severity Severity, directive, description string) {
+		result = append(result, Weakness{
+			RuleID:      rule,
+			Severity:    severity,
+			Directive:   directive,
+			Origin:      policy.Origin,
+			Description: description,
+		})
+	}
+
+	script, ok := policy.Get("script-src")
+	if !ok {
+		script, ok = policy.Get("default-src")
+	}
+
+	if ok {
+		evaluateScript(script, weakness)
+	}
+
+	return result
+}
+
+// evaluateScript checks the sources of the directive governing scripts.
+func evaluateScript(d Directive, weakness func(rule string, severity Severity, directive, description string)) {
+	nonceOrHash := false
+	strictDynamic := hasKeyword(d, "strict-dynamic")
+
+	for _, s := range d.Sources {
+		if s.Type == SourceNonce || s.Type == SourceHash {
+			nonceOrHash = true
+		}
+	}
+
+	for _, s := range d.Sources {
+		switch s.Type {
+		case SourceKeyword:
+			if s.Value == "unsafe-inline" && !nonceOrHash {
+				weakness(RuleUnsafeInline, SeverityHigh, d.Name,
+					"'unsafe-inline' without nonces or hashes allows the execution of inline scripts")
+			}
+
+			if s.Value == "unsafe-eval" {
+				weakness(RuleUnsafeEval, SeverityMedium, d.Name,
+					"'unsafe-eval' allows the execution of code injected into eval and similar functions")
+			}
+		case SourceScheme:
+			if strictDynamic {
+				continue
+			}
+
+			if s.Scheme == "data" {
+				weakness(RuleScriptDataURI, SeverityHigh, d.Name,
+					"data: allows the execution of scripts from data URIs")
+			} else if s.Scheme == "http" || s.Scheme == "https" {
+				weakness(RuleScriptScheme, SeverityHigh, d.Name,
+					fmt.Sprintf("%s allows scripts to be loaded from any host", s.Expression))
+			}
+		case SourceHost:
+			if !strictDynamic && s.Host == "*" {
+				weakness(RuleScriptWildcard, SeverityHigh, d.Name,
+					fmt.Sprintf("%s allows scripts to be loaded from any host", s.Expression))
+			}
+		case SourceOther, SourceNonce, SourceHash:
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateCSP(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    []string
+	}{
+		{
+			name:    "no policies",
+			headers: map[string]string{},
+			want:    []string{},
+		},
+		{
+			name: "strict policy",
+			headers: map[string]string{
+				csprecon.HeaderCSP: "script-src 'nonce-r4nd0m' 'strict-dynamic' https: 'unsafe-inline'; " +
+					"object-src 'none'; base-uri 'none'; frame-ancestors 'self'",
+			},
+			want: []string{},
+		},
+		{
+			name: "default-src none",
+			headers: map[string]string{
+				csprecon.HeaderCSP: "default-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+			},
+			want: []string{},
+		},
+		{
+			name: "weak script-src",
+			headers: map[string]string{
+				csprecon.HeaderCSP: "script-src 'self' 'unsafe-inline' 'unsafe-eval' * https: data:; frame-ancestors 'self'",
+			},
+			want: []string{
+				csprecon.RuleUnsafeInline,
+				csprecon.RuleScriptWildcard,
+				csprecon.RuleScriptScheme,
+				csprecon.RuleScriptDataURI,
+				csprecon.RuleMissingObjectSrc,
+				csprecon.RuleUnsafeEval,
+				csprecon.RuleMissingBaseURI,
+			},
+		},
+		{
+			name: "report only",
+			headers: map[string]string{
+				csprecon.HeaderCSPReportOnly: "default-src 'self'; object-src 'none'; base-uri 'self'",
+			},
+			want: []string{csprecon.RuleReportOnly},
+		},
+		{
+			name: "missing script-src and frame-ancestors",
+			headers: map[string]string{
+				csprecon.HeaderCSP: "img-src *; object-src 'none'; base-uri 'self'",
+			},
+			want: []string{csprecon.RuleMissingScriptSrc, csprecon.RuleMissingFrameAncestor},
+		},
+		{
+			name: "strict policy with a separate policy",
+			headers: map[string]string{
+				csprecon.HeaderCSP: "script-src 'self'; object-src 'none'; base-uri 'none'; " +
+					"frame-ancestors 'self', upgrade-insecure-requests",
+			},
+			want: []string{},
+		},
+		{
+			name: "directives split across policies",
+			headers: map[string]string{
+				csprecon.HeaderCSP:        "default-src 'self'; object-src 'none', upgrade-insecure-requests",
+				csprecon.HeaderXWebKitCSP: "frame-ancestors 'none'",
+			},
+			want: []string{csprecon.RuleMissingBaseURI},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := []csprecon.Policy{}
+
+			for header, value := range tt.headers {
+				for _, p := range csprecon.ParsePolicyList(value) {
+					p.Origin = header
+					policies = append(policies, p)
+				}
+			}
+
+			got := []string{}
+			for _, w := range csprecon.EvaluateCSP(policies) {
+				got = append(got, w.RuleID)
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
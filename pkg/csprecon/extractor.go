@@ -0,0 +1,222 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/edoardottt/csprecon/pkg/input"
+)
+
+const (
+	ExtractorTLSSAN = OriginTLSSAN
+)
+
+var ErrDuplicateExtractor = errors.New("extractor already registered")
+
+// ExtractorInput is a response (of the redirect chain) handed to
+// the extractors. Body is nil for redirect responses, when the
+// body is not read (-body-limit 0) and for the extractors not
+// implementing BodyExtractor.
+type ExtractorInput struct {
+	Request *http.Request
+	Header  http.Header
+	Body    io.Reader
+	TLS     *tls.ConnectionState
+}
+
+// Extractor is a discovery source. Extract returns the findings of a
+// response, each one with its own origin so that it can be filtered.
+// Extractors are called concurrently and must be safe for concurrent use.
+type Extractor interface {
+	Name() string
+	Extract(in *ExtractorInput) ([]Finding, error)
+}
+
+// BodyExtractor is an Extractor reading the body of the final response.
+// The body is buffered, up to the body limit, only if one of the selected
+// extractors needs it.
+type BodyExtractor interface {
+	Extractor
+	NeedsBody() bool
+}
+
+// needsBody reports whether the extractor reads the body.
+func needsBody(e Extractor) bool {
+	b, ok := e.(BodyExtractor)
+
+	return ok && b.NeedsBody()
+}
+
+// Registry holds the extractors available to a Runner,
+// in registration order.
+type Registry struct {
+	mutex      sync.RWMutex
+	extractors []Extractor
+}
+
+// NewRegistry returns a registry holding the built-in extractors:
+// the header extractors (named after their header) and tls-san.
+func NewRegistry() *Registry {
+	registry := &Registry{extractors: []Extractor{}}
+	r := CompileRegex(DomainRegex)
+
+	for _, e := range DefaultHeaderExtractors() {
+		e.regex = r
+		_ = registry.Register(e)
+	}
+
+	_ = registry.Register(tlsExtractor{regex: r})
+
+	return registry
+}
+
+// Register adds the extractor to the registry. Names are case-insensitive
+// and must be unique.
+func (reg *Registry) Register(e Extractor) error {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	name := strings.ToLower(e.Name())
+	if name == "" || name == ExtractorsAll {
+		return fmt.Errorf("extractor %q: %w", name, input.ErrInvalidValue)
+	}
+
+	for _, known := range reg.extractors {
+		if strings.EqualFold(known.Name(), name) {
+			return fmt.Errorf("extractor %s: %w", name, ErrDuplicateExtractor)
+		}
+	}
+
+	reg.extractors = append(reg.extractors, e)
+
+	return nil
+}
+
+// Names returns the names of the registered extractors.
+func (reg *Registry) Names() []string {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+
+	result := []string{}
+
+	for _, e := range reg.extractors {
+		result = append(result, strings.ToLower(e.Name()))
+	}
+
+	return result
+}
+
+// Select returns the extractors with the given names (case-insensitive),
+// all of them if names contains "all". Unknown names are reported in the
+// error, the known ones are returned anyway.
+func (reg *Registry) Select(names []string) ([]Extractor, error) {
+	reg.mutex.RLock()
+	defer reg.mutex.RUnlock()
+
+	result := []Extractor{}
+	errs := []error{}
+	selected := map[string]struct{}{}
+	all := false
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == ExtractorsAll {
+			all = true
+
+			continue
+		}
+
+		found := false
+
+		for _, e := range reg.extractors {
+			if strings.EqualFold(e.Name(), name) {
+				found = true
+
+				if _, ok := selected[name]; !ok {
+					selected[name] = struct{}{}
+					result = append(result, e)
+				}
+			}
+		}
+
+		if !found {
+			errs = append(errs, fmt.Errorf("extractor %s: %w", name, input.ErrInvalidValue))
+		}
+	}
+
+	if all {
+		result = append([]Extractor{}, reg.extractors...)
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// ExtractorNames returns the names of the extractors enabled by
+// the options (-x, -hx and -ts).
+func ExtractorNames(options *input.Options) []string {
+	result := append([]string{}, options.Extractors...)
+	result = append(result, options.HeaderExtract...)
+
+	if options.TLSSAN {
+		result = append(result, ExtractorTLSSAN)
+	}
+
+	return result
+}
+
+// runExtractors returns the findings of the extractors for the response.
+func runExtractors(extractors []Extractor, in *ExtractorInput, body []byte, log Logger) []Finding {
+	result := []Finding{}
+
+	for _, e := range extractors {
+		in.Body = nil
+		if body != nil && needsBody(e) {
+			in.Body = bytes.NewReader(body)
+		}
+
+		findings, err := e.Extract(in)
+		if err != nil {
+			log.Debugf("Extractor %s on %s: %s", e.Name(), in.Request.URL, err)
+
+			continue
+		}
+
+		result = append(result, findings...)
+	}
+
+	return result
+}
+
+// tlsExtractor returns the hosts found in the leaf certificate.
+type tlsExtractor struct {
+	regex *regexp.Regexp
+}
+
+// Name returns the name of the extractor.
+func (t tlsExtractor) Name() string {
+	return ExtractorTLSSAN
+}
+
+// Extract returns the TLS findings of the response.
+func (t tlsExtractor) Extract(in *ExtractorInput) ([]Finding, error) {
+	cert := peerCertificate(in.TLS)
+	if cert == nil {
+		return []Finding{}, nil
+	}
+
+	return TLSFindings([]*x509.Certificate{cert}, t.regex), nil
+}
@@ -0,0 +1,170 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bodyExtractor reports the hosts of the api_url values of a JSON body.
+type bodyExtractor struct{}
+
+func (bodyExtractor) Name() string {
+	return "api-url"
+}
+
+func (bodyExtractor) NeedsBody() bool {
+	return true
+}
+
+func (bodyExtractor) Extract(in *csprecon.ExtractorInput) ([]csprecon.Finding, error) {
+	if in.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []csprecon.Finding{}
+
+	for _, m := range regexp.MustCompile(`"api_url":\s*"https?://([^/"]+)`).FindAllSubmatch(body, -1) {
+		result = append(result, csprecon.Finding{
+			Domain:    string(m[1]),
+			HostType:  csprecon.HostConcrete,
+			Directive: in.Request.URL.Path,
+			Origin:    "api-url",
+		})
+	}
+
+	return result, nil
+}
+
+func TestRegistry(t *testing.T) {
+	registry := csprecon.NewRegistry()
+	require.Equal(t, []string{
+		"access-control-allow-origin", "link", "alt-svc", "permissions-policy",
+		"set-cookie", "timing-allow-origin", "tls-san",
+	}, registry.Names())
+
+	require.NoError(t, registry.Register(bodyExtractor{}))
+	require.ErrorIs(t, registry.Register(bodyExtractor{}), csprecon.ErrDuplicateExtractor)
+
+	got, err := registry.Select([]string{"Link", "api-url", "link"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Equal(t, "link", got[0].Name())
+	require.Equal(t, "api-url", got[1].Name())
+
+	got, err = registry.Select([]string{"link", "all"})
+	require.NoError(t, err)
+	require.Len(t, got, len(registry.Names()))
+
+	got, err = registry.Select([]string{"all", "typo"})
+	require.ErrorIs(t, err, input.ErrInvalidValue)
+	require.ErrorContains(t, err, "typo")
+	require.Len(t, got, len(registry.Names()))
+
+	got, err = registry.Select([]string{"server", "tls-san"})
+	require.ErrorIs(t, err, input.ErrInvalidValue)
+	require.Len(t, got, 1)
+
+	require.Equal(t, []string{"api-url", "link", "tls-san"}, csprecon.ExtractorNames(&input.Options{
+		Extractors:    []string{"api-url"},
+		HeaderExtract: []string{"link"},
+		TLSSAN:        true,
+	}))
+}
+
+func TestCheckCSPExtractors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Header().Set(csprecon.HeaderLink, "<https://hop.example.com>; rel=preconnect")
+			http.Redirect(w, r, "/config", http.StatusFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(csprecon.HeaderCSP, "script-src csp.example.com")
+		w.Header().Set(csprecon.HeaderLink, "<https://final.example.com>; rel=dns-prefetch")
+		_, _ = w.Write([]byte(`{"api_url": "https://api.example.com/v1", "cdn": "https://cdn.example.com"}`))
+	}))
+	defer server.Close()
+
+	registry := csprecon.NewRegistry()
+	require.NoError(t, registry.Register(bodyExtractor{}))
+
+	extractors, err := registry.Select([]string{"link", "api-url"})
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	got, err := csprecon.CheckCSP(server.URL, client, csprecon.RequestOptions{
+		BodyLimit:    csprecon.KB,
+		MaxRedirects: 1,
+		Extractors:   extractors,
+	})
+	require.NoError(t, err)
+	require.False(t, got.BodyTruncated)
+
+	findings := []string{}
+	for _, f := range got.Findings {
+		findings = append(findings, f.Domain+" "+f.Directive+" "+f.Origin+" "+strconv.Itoa(f.Hop))
+	}
+
+	require.Equal(t, []string{
+		"hop.example.com preconnect Link 0",
+		"final.example.com dns-prefetch Link 1",
+		"api.example.com /config api-url 1",
+	}, findings)
+	require.Equal(t, []string{"csp.example.com", "hop.example.com", "final.example.com", "api.example.com"},
+		csprecon.FindingsDomains(csprecon.ResponseFindings(got, csprecon.CompileRegex(csprecon.DomainRegex))))
+
+	// Headers only: the extractors get no body.
+	got, err = csprecon.CheckCSP(server.URL+"/config", client, csprecon.RequestOptions{Extractors: extractors})
+	require.NoError(t, err)
+	require.Len(t, got.Findings, 1)
+}
+
+func TestCheckCSPHeaderExtractors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set(csprecon.HeaderLink, "<https://final.example.com>; rel=dns-prefetch")
+		_, _ = w.Write([]byte(`<head><meta http-equiv="Content-Security-Policy" content="script-src a.example.com"></head>`))
+		_, _ = w.Write([]byte(strings.Repeat("a", 2*csprecon.KB)))
+	}))
+	defer server.Close()
+
+	extractors, err := csprecon.NewRegistry().Select([]string{"link"})
+	require.NoError(t, err)
+
+	// The body is not buffered for the header extractors: reading
+	// stops at the end of the head, before the body limit.
+	got, err := csprecon.CheckCSP(server.URL, server.Client(), csprecon.RequestOptions{
+		BodyLimit:  csprecon.KB,
+		Extractors: extractors,
+	})
+	require.NoError(t, err)
+	require.False(t, got.BodyTruncated)
+	require.Len(t, got.Policies, 1)
+	require.Equal(t, []string{"final.example.com"}, csprecon.FindingsDomains(got.Findings))
+}
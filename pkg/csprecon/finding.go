@@ -0,0 +1,295 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/edoardottt/golazy"
+)
+
+const (
+	OriginMeta = "meta"
+)
+
+// HostType is the kind of host of a finding.
+type HostType int
+
+const (
+	HostConcrete HostType = iota
+	HostWildcard
+	HostIPv4
+	HostIPv6
+)
+
+// nolint: gochecknoglobals
+var hostTypeNames = map[HostType]string{
+	HostConcrete: "concrete",
+	HostWildcard: "wildcard",
+	HostIPv4:     "ipv4",
+	HostIPv6:     "ipv6",
+}
+
+const (
+	RangePublic    = "public"
+	RangePrivate   = "private"
+	RangeLoopback  = "loopback"
+	RangeLinkLocal = "link-local"
+)
+
+// String returns the name of the host type.
+func (t HostType) String() string {
+	if name, ok := hostTypeNames[t]; ok {
+		return name
+	}
+
+	return hostTypeNames[HostConcrete]
+}
+
+// Finding is a domain discovered in a policy, along with the
+// directive, the origin (and its disposition) and the source
+// expression it was found in. IPRange is set only for IP literals.
+// Hop is the position in the redirect chain of the response
+// delivering the policy, 0 being the first response.
+type Finding struct {
+	Domain      string
+	HostType    HostType
+	IPRange     string
+	Directive   string
+	Origin      string
+	Disposition string
+	Source      Source
+	Hop         int
+}
+
+// HostTypeOf returns the host type of the input host.
+func HostTypeOf(host string) HostType {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		if ip.To4() != nil {
+			return HostIPv4
+		}
+
+		return HostIPv6
+	}
+
+	if strings.Contains(host, "*") {
+		return HostWildcard
+	}
+
+	return HostConcrete
+}
+
+// IPRange returns the range of the IP: loopback, private, link-local or public.
+func IPRange(ip net.IP) string {
+	switch {
+	case ip.IsLoopback():
+		return RangeLoopback
+	case ip.IsPrivate():
+		return RangePrivate
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return RangeLinkLocal
+	default:
+		return RangePublic
+	}
+}
+
+// Type returns the type of the finding, including the IP range for IP literals.
+func (f Finding) Type() string {
+	if f.IPRange != "" {
+		return f.HostType.String() + "-" + f.IPRange
+	}
+
+	return f.HostType.String()
+}
+
+// WildcardBase returns the base domain of a wildcard host, i.e. the part
+// after the last wildcard label (*.fbcdn.net and dc.*.fbcdn.net return
+// fbcdn.net). Concrete hosts are returned unchanged.
+func WildcardBase(host string) string {
+	if i := strings.LastIndex(host, "*."); i >= 0 {
+		return host[i+2:]
+	}
+
+	return host
+}
+
+// PolicyFindings returns the findings matching the regex found
+// in the host sources of the policies.
+func PolicyFindings(policies []Policy, r *regexp.Regexp) []Finding {
+	result := []Finding{}
+
+	for _, policy := range policies {
+		for _, d := range policy.Directives {
+			for _, s := range d.Sources {
+				if s.Type != SourceHost {
+					continue
+				}
+
+				result = append(result, sourceFindings(s, d.Name, policy.Origin, r)...)
+			}
+		}
+	}
+
+	return golazy.RemoveDuplicateValues(result)
+}
+
+// ResponseFindings returns the findings of every response of the
+// redirect chain, including the report-to endpoints of the final one
+// and the findings of the extractors.
+func ResponseFindings(resp CSPResponse, r *regexp.Regexp) []Finding {
+	result := []Finding{}
+
+	for i, hop := range resp.Hops {
+		result = append(result, withHop(PolicyFindings(hop.Policies, r), i)...)
+	}
+
+	result = append(result, withHop(PolicyFindings(resp.Policies, r), len(resp.Hops))...)
+
+	result = append(result, withHop(EndpointFindings(resp.ReportEndpoints, r), len(resp.Hops))...)
+
+	return append(result, resp.Findings...)
+}
+
+// withHop sets the redirect chain position of the findings.
+func withHop(findings []Finding, hop int) []Finding {
+	for i := range findings {
+		findings[i].Hop = hop
+	}
+
+	return findings
+}
+
+// sourceFindings returns the findings of a single host source.
+func sourceFindings(s Source, directive, origin string, r *regexp.Regexp) []Finding {
+	result := []Finding{}
+
+	if ip := net.ParseIP(strings.Trim(s.Host, "[]")); ip != nil {
+		return append(result, Finding{
+			Domain:      ip.String(),
+			HostType:    HostTypeOf(s.Host),
+			IPRange:     IPRange(ip),
+			Directive:   directive,
+			Origin:      origin,
+			Disposition: OriginDisposition(origin),
+			Source:      s,
+		})
+	}
+
+	for _, domain := range r.FindAllString(s.Host, -1) {
+		result = append(result, Finding{
+			Domain:      domain,
+			HostType:    HostTypeOf(domain),
+			Directive:   directive,
+			Origin:      origin,
+			Disposition: OriginDisposition(origin),
+			Source:      s,
+		})
+	}
+
+	return result
+}
+
+// PolicyDomains returns the list of domains matching the regex found
+// in the host sources of the policies.
+func PolicyDomains(policies []Policy, r *regexp.Regexp) []string {
+	return FindingsDomains(PolicyFindings(policies, r))
+}
+
+// FindingsDomains returns the unique domains of the findings.
+func FindingsDomains(findings []Finding) []string {
+	result := []string{}
+
+	for _, f := range findings {
+		result = append(result, f.Domain)
+	}
+
+	return golazy.RemoveDuplicateValues(result)
+}
+
+// FilterFindings returns the findings belonging to the input domains.
+func FilterFindings(findings []Finding, domains []string) []Finding {
+	result := []Finding{}
+
+	for _, f := range findings {
+		if DomainOk(f.Domain, domains) {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// FilterFindingsByOrigin returns the findings whose origin matches
+// the input filters (see OriginOk).
+func FilterFindingsByOrigin(findings []Finding, filters []string) []Finding {
+	result := []Finding{}
+
+	for _, f := range findings {
+		if OriginOk(f.Origin, filters) {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// WildcardFindings applies the wildcard mode to the findings:
+// input.WildcardConcrete drops wildcard hosts, input.WildcardBase
+// replaces them with their base domain, every other mode keeps them.
+func WildcardFindings(findings []Finding, mode string) []Finding {
+	result := []Finding{}
+
+	for _, f := range findings {
+		if f.HostType == HostWildcard {
+			switch mode {
+			case input.WildcardConcrete:
+				continue
+			case input.WildcardBase:
+				f.Domain = WildcardBase(f.Domain)
+			}
+		}
+
+		result = append(result, f)
+	}
+
+	return golazy.RemoveDuplicateValues(result)
+}
+
+// DomainGroup is a registrable domain with the hosts belonging to it.
+type DomainGroup struct {
+	Domain string
+	Hosts  []string
+}
+
+// GroupFindings groups the domains of the findings by registrable domain.
+// Hosts without a registrable domain (e.g. public suffixes) are
+// grouped under themselves.
+func GroupFindings(findings []Finding) []DomainGroup {
+	result := []DomainGroup{}
+	index := map[string]int{}
+
+	for _, host := range FindingsDomains(findings) {
+		domain, err := RegistrableDomain(host)
+		if err != nil {
+			domain = host
+		}
+
+		i, ok := index[domain]
+		if !ok {
+			i = len(result)
+			index[domain] = i
+			result = append(result, DomainGroup{Domain: domain, Hosts: []string{}})
+		}
+
+		result[i].Hosts = append(result[i].Hosts, host)
+	}
+
+	return result
+}
@@ -0,0 +1,150 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyFindings(t *testing.T) {
+	policies := csprecon.ParsePolicyList("script-src 'self' https://cdn.example.com; img-src *.example.com cdn.example.com")
+	policies[0].Origin = "Content-Security-Policy"
+
+	got := csprecon.PolicyFindings(policies, csprecon.CompileRegex(csprecon.DomainRegex))
+	want := []csprecon.Finding{
+		{
+			Domain:      "cdn.example.com",
+			Directive:   "script-src",
+			Origin:      "Content-Security-Policy",
+			Disposition: csprecon.DispositionEnforce,
+			Source:      csprecon.ParseSource("https://cdn.example.com"),
+		},
+		{
+			Domain:      "*.example.com",
+			HostType:    csprecon.HostWildcard,
+			Directive:   "img-src",
+			Origin:      "Content-Security-Policy",
+			Disposition: csprecon.DispositionEnforce,
+			Source:      csprecon.ParseSource("*.example.com"),
+		},
+		{
+			Domain:      "cdn.example.com",
+			Directive:   "img-src",
+			Origin:      "Content-Security-Policy",
+			Disposition: csprecon.DispositionEnforce,
+			Source:      csprecon.ParseSource("cdn.example.com"),
+		},
+	}
+	require.Equal(t, want, got)
+
+	require.Equal(t, []string{"cdn.example.com", "*.example.com"}, csprecon.FindingsDomains(got))
+	require.Equal(t, want[1:2], csprecon.FilterFindings(got, []string{"*.example.com"}))
+}
+
+func TestGroupFindings(t *testing.T) {
+	policies := csprecon.ParsePolicyList("script-src a.example.co.uk *.b.example.co.uk; img-src cdn.other.com")
+	got := csprecon.GroupFindings(csprecon.PolicyFindings(policies, csprecon.CompileRegex(csprecon.DomainRegex)))
+	want := []csprecon.DomainGroup{
+		{Domain: "example.co.uk", Hosts: []string{"a.example.co.uk", "*.b.example.co.uk"}},
+		{Domain: "other.com", Hosts: []string{"cdn.other.com"}},
+	}
+	require.Equal(t, want, got)
+}
+
+func TestWildcardFindings(t *testing.T) {
+	policies := csprecon.ParsePolicyList("script-src *.fbcdn.net dc.*.google.com www.google.com fbcdn.net")
+	findings := csprecon.PolicyFindings(policies, csprecon.CompileRegex(csprecon.DomainRegex))
+
+	tests := []struct {
+		name string
+		mode string
+		want []string
+	}{
+		{
+			name: "all",
+			mode: input.WildcardAll,
+			want: []string{"*.fbcdn.net", "dc.*.google.com", "www.google.com", "fbcdn.net"},
+		},
+		{
+			name: "concrete",
+			mode: input.WildcardConcrete,
+			want: []string{"www.google.com", "fbcdn.net"},
+		},
+		{
+			name: "base",
+			mode: input.WildcardBase,
+			want: []string{"fbcdn.net", "google.com", "www.google.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := csprecon.FindingsDomains(csprecon.WildcardFindings(findings, tt.mode))
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	require.Equal(t, csprecon.HostWildcard, csprecon.HostTypeOf("dc.*.google.com"))
+	require.Equal(t, csprecon.HostConcrete, csprecon.HostTypeOf("www.google.com"))
+	require.Equal(t, "google.com", csprecon.WildcardBase("*.dc.*.google.com"))
+	require.Equal(t, "www.google.com", csprecon.WildcardBase("www.google.com"))
+}
+
+func TestPolicyFindingsIP(t *testing.T) {
+	policies := csprecon.ParsePolicyList("connect-src http://10.1.2.3:8080 [2001:db8::1] https://[::1]:8443 169.254.169.254 8.8.8.8")
+	got := []string{}
+
+	for _, f := range csprecon.PolicyFindings(policies, csprecon.CompileRegex(csprecon.DomainRegex)) {
+		got = append(got, f.Domain+" "+f.Type()+" "+f.Source.Port)
+	}
+
+	want := []string{
+		"10.1.2.3 ipv4-private 8080",
+		"2001:db8::1 ipv6-public ",
+		"::1 ipv6-loopback 8443",
+		"169.254.169.254 ipv4-link-local ",
+		"8.8.8.8 ipv4-public ",
+	}
+	require.Equal(t, want, got)
+}
+
+func TestFilterFindingsByOrigin(t *testing.T) {
+	header := csprecon.ParsePolicyList("script-src a.example.com")
+	header[0].Origin = csprecon.HeaderCSP
+	reportOnly := csprecon.ParsePolicyList("script-src b.example.com")
+	reportOnly[0].Origin = csprecon.HeaderCSPReportOnly
+	meta := csprecon.ParsePolicyList("script-src c.example.com")
+	meta[0].Origin = csprecon.OriginMeta
+
+	policies := append(append(header, reportOnly...), meta...)
+	findings := csprecon.PolicyFindings(policies, csprecon.CompileRegex(csprecon.DomainRegex))
+
+	tests := []struct {
+		name    string
+		filters []string
+		want    []string
+	}{
+		{name: "no filters", filters: nil, want: []string{}},
+		{name: "meta", filters: []string{"meta"}, want: []string{"c.example.com"}},
+		{name: "header", filters: []string{"header"}, want: []string{"a.example.com", "b.example.com"}},
+		{name: "report-only", filters: []string{"report-only"}, want: []string{"b.example.com"}},
+		{name: "enforce", filters: []string{"enforce"}, want: []string{"a.example.com", "c.example.com"}},
+		{name: "header name", filters: []string{"content-security-policy"}, want: []string{"a.example.com"}},
+		{name: "multiple", filters: []string{"meta", "report-only"}, want: []string{"b.example.com", "c.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := csprecon.FindingsDomains(csprecon.FilterFindingsByOrigin(findings, tt.filters))
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
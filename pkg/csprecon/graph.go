@@ -0,0 +1,150 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/edoardottt/csprecon/pkg/output"
+)
+
+// Graph collects the findings of the targets as a graph of trust
+// relationships. Nodes are hosts: a target found recursively is
+// the same node as the host it was found as, so the graph shows
+// the whole trust chain. It's safe for concurrent use.
+type Graph struct {
+	mutex sync.Mutex
+	nodes map[string]*output.GraphNode
+	edges map[graphEdgeKey]*output.GraphEdge
+}
+
+type graphEdgeKey struct {
+	from, to, directive, source string
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{
+		nodes: map[string]*output.GraphNode{},
+		edges: map[graphEdgeKey]*output.GraphEdge{},
+	}
+}
+
+// Add adds the target, scanned at the given depth, and an edge
+// to the host of every finding. Self references are ignored.
+func (g *Graph) Add(targetURL string, depth int, findings []Finding) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	from := InputHost(targetURL)
+	target := g.node(from, HostTypeOf(from).String())
+
+	if target.Type != output.NodeTarget || depth < target.Depth {
+		target.Type, target.URL, target.Depth = output.NodeTarget, targetURL, depth
+	}
+
+	for _, f := range findings {
+		if f.Domain == from {
+			continue
+		}
+
+		g.node(f.Domain, f.Type())
+
+		key := graphEdgeKey{from: from, to: f.Domain, directive: f.Directive, source: f.Source.Expression}
+
+		edge, ok := g.edges[key]
+		if !ok {
+			edge = &output.GraphEdge{From: from, To: f.Domain, Directive: f.Directive, Source: f.Source.Expression}
+			g.edges[key] = edge
+		}
+
+		if !slices.Contains(edge.Origins, f.Origin) {
+			edge.Origins = append(edge.Origins, f.Origin)
+		}
+	}
+}
+
+// node returns the node of the host, adding it if missing.
+func (g *Graph) node(id, hostType string) *output.GraphNode {
+	if n, ok := g.nodes[id]; ok {
+		return n
+	}
+
+	n := &output.GraphNode{ID: id, Type: output.NodeHost, HostType: hostType}
+	g.nodes[id] = n
+
+	return n
+}
+
+// Data returns the nodes and the edges of the graph, sorted.
+func (g *Graph) Data() output.Graph {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	result := output.Graph{Nodes: []output.GraphNode{}, Edges: []output.GraphEdge{}}
+
+	for _, n := range g.nodes {
+		result.Nodes = append(result.Nodes, *n)
+	}
+
+	for _, e := range g.edges {
+		result.Edges = append(result.Edges, *e)
+	}
+
+	sort.Slice(result.Nodes, func(i, j int) bool {
+		a, b := result.Nodes[i], result.Nodes[j]
+		if a.Type != b.Type {
+			return a.Type == output.NodeTarget
+		}
+
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+
+		return a.ID < b.ID
+	})
+
+	sort.Slice(result.Edges, func(i, j int) bool {
+		a, b := result.Edges[i], result.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+
+		if a.To != b.To {
+			return a.To < b.To
+		}
+
+		if a.Directive != b.Directive {
+			return a.Directive < b.Directive
+		}
+
+		return a.Source < b.Source
+	})
+
+	return result
+}
+
+// FormatGraph returns the graph in the format
+// (dot, graphml, mermaid or json).
+func FormatGraph(g *output.Graph, format string) ([]byte, error) {
+	switch format {
+	case input.GraphDOT:
+		return output.FormatGraphDOT(g), nil
+	case input.GraphGraphML:
+		return output.FormatGraphGraphML(g)
+	case input.GraphMermaid:
+		return output.FormatGraphMermaid(g), nil
+	case input.GraphJSON:
+		return output.FormatGraphJSON(g)
+	default:
+		return nil, fmt.Errorf("graph format %s: %w", format, input.ErrInvalidValue)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/edoardottt/csprecon/pkg/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func headerFindings(csp string) []csprecon.Finding {
+	policy := csprecon.ParsePolicy(csp)
+	policy.Origin = csprecon.HeaderCSP
+
+	return csprecon.PolicyFindings([]csprecon.Policy{policy}, csprecon.CompileRegex(csprecon.DomainRegex))
+}
+
+func testGraph() *output.Graph {
+	g := csprecon.NewGraph()
+	g.Add("https://www.example.com/login", 0, headerFindings("script-src https://cdn.example.com www.example.com 'self'"))
+	g.Add("https://cdn.example.com", 1, headerFindings("default-src js.example.net"))
+
+	data := g.Data()
+
+	return &data
+}
+
+func TestGraph(t *testing.T) {
+	g := testGraph()
+
+	require.Equal(t, []output.GraphNode{
+		{ID: "www.example.com", Type: output.NodeTarget, HostType: "concrete", URL: "https://www.example.com/login"},
+		{ID: "cdn.example.com", Type: output.NodeTarget, HostType: "concrete", URL: "https://cdn.example.com", Depth: 1},
+		{ID: "js.example.net", Type: output.NodeHost, HostType: "concrete"},
+	}, g.Nodes)
+	require.Equal(t, []output.GraphEdge{
+		{From: "cdn.example.com", To: "js.example.net", Directive: "default-src", Source: "js.example.net", Origins: []string{csprecon.HeaderCSP}},
+		{From: "www.example.com", To: "cdn.example.com", Directive: "script-src", Source: "https://cdn.example.com", Origins: []string{csprecon.HeaderCSP}},
+	}, g.Edges)
+}
+
+func TestFormatGraph(t *testing.T) {
+	g := testGraph()
+
+	dot, err := csprecon.FormatGraph(g, input.GraphDOT)
+	require.NoError(t, err)
+	require.Equal(t, `digraph csprecon {
+  rankdir=LR;
+  "www.example.com" [shape=box];
+  "cdn.example.com" [shape=box];
+  "js.example.net" [shape=ellipse];
+  "cdn.example.com" -> "js.example.net" [label="default-src"];
+  "www.example.com" -> "cdn.example.com" [label="script-src: https://cdn.example.com"];
+}`, string(dot))
+
+	mermaid, err := csprecon.FormatGraph(g, input.GraphMermaid)
+	require.NoError(t, err)
+	require.Equal(t, `flowchart LR
+  n0["www.example.com"]
+  n1["cdn.example.com"]
+  n2(["js.example.net"])
+  n1 -->|"default-src"| n2
+  n0 -->|"script-src: https://cdn.example.com"| n1`, string(mermaid))
+
+	graphML, err := csprecon.FormatGraph(g, input.GraphGraphML)
+	require.NoError(t, err)
+	require.Contains(t, string(graphML), `<graph id="csprecon" edgedefault="directed">`)
+	require.Contains(t, string(graphML), `<edge source="www.example.com" target="cdn.example.com">`)
+	require.Contains(t, string(graphML), `<data key="url">https://www.example.com/login</data>`)
+
+	data, err := csprecon.FormatGraph(g, input.GraphJSON)
+	require.NoError(t, err)
+
+	var got output.Graph
+
+	require.NoError(t, json.Unmarshal(data, &got))
+	require.Equal(t, *g, got)
+
+	_, err = csprecon.FormatGraph(g, "svg")
+	require.ErrorIs(t, err, input.ErrInvalidValue)
+}
+
+func TestRunGraph(t *testing.T) {
+	policies := map[string]string{
+		"a.test":   "script-src b.a.test; img-src other.test",
+		"b.a.test": "script-src c.a.test",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(csprecon.HeaderCSP, policies[r.Host])
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	options := input.DefaultOptions()
+	options.Input = "a.test"
+	options.Concurrency = 2
+	options.Silent = true
+	options.RecursionDepth = 1
+	options.Graph = input.GraphJSON
+	options.Output = out
+
+	runner := newTestRunner(t, options, server)
+	require.NoError(t, runner.Run(context.Background()))
+
+	var got output.Graph
+
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got))
+
+	edges := []string{}
+	for _, e := range got.Edges {
+		edges = append(edges, e.From+" -> "+e.To)
+	}
+
+	// c.a.test is beyond the recursion depth: found, not scanned.
+	require.Equal(t, []string{"a.test -> b.a.test", "a.test -> other.test", "b.a.test -> c.a.test"}, edges)
+	require.Len(t, got.Nodes, 4)
+	require.Equal(t, output.NodeTarget, got.Nodes[1].Type)
+	require.Equal(t, 1, got.Nodes[1].Depth)
+}
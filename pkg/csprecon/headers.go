@@ -0,0 +1,222 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	HeaderACAO              = "Access-Control-Allow-Origin"
+	HeaderLink              = "Link"
+	HeaderAltSvc            = "Alt-Svc"
+	HeaderPermissionsPolicy = "Permissions-Policy"
+	HeaderSetCookie         = "Set-Cookie"
+	HeaderTimingAllowOrigin = "Timing-Allow-Origin"
+	// ExtractorsAll enables every extractor.
+	ExtractorsAll = "all"
+)
+
+// HeaderExtractor pulls host sources out of the values of a header.
+// Each returned directive is named after the part of the header
+// the sources were found in (e.g. the rel of a Link, the feature
+// of a Permissions-Policy). It's an Extractor named after the
+// header (lowercase).
+type HeaderExtractor struct {
+	Header string
+	Parse  func(value string) []Directive
+	regex  *regexp.Regexp
+}
+
+// DefaultHeaderExtractors returns the built-in header extractors.
+func DefaultHeaderExtractors() []HeaderExtractor {
+	return []HeaderExtractor{
+		{Header: HeaderACAO, Parse: extractOrigins("allow-origin", " ")},
+		{Header: HeaderLink, Parse: extractLink},
+		{Header: HeaderAltSvc, Parse: extractAltSvc},
+		{Header: HeaderPermissionsPolicy, Parse: extractPermissionsPolicy},
+		{Header: HeaderSetCookie, Parse: extractCookieDomain},
+		{Header: HeaderTimingAllowOrigin, Parse: extractOrigins("allow-origin", ",")},
+	}
+}
+
+// Name returns the name of the extractor.
+func (e HeaderExtractor) Name() string {
+	return strings.ToLower(e.Header)
+}
+
+// Extract returns the findings in the header of the response.
+func (e HeaderExtractor) Extract(in *ExtractorInput) ([]Finding, error) {
+	r := e.regex
+	if r == nil {
+		r = CompileRegex(DomainRegex)
+	}
+
+	return HeaderFindings(in.Header, []HeaderExtractor{e}, r), nil
+}
+
+// HeaderFindings returns the findings matching the regex found in
+// the headers by the extractors. The origin of the findings is the
+// header name.
+func HeaderFindings(header http.Header, extractors []HeaderExtractor, r *regexp.Regexp) []Finding {
+	result := []Finding{}
+
+	for _, e := range extractors {
+		for _, value := range header.Values(e.Header) {
+			for _, d := range e.Parse(value) {
+				for _, s := range d.Sources {
+					if s.Type == SourceHost {
+						result = append(result, sourceFindings(s, d.Name, e.Header, r)...)
+					}
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// extractOrigins returns an extractor of the origins listed in the
+// header, separated by sep. '*' and 'null' are ignored.
+func extractOrigins(name, sep string) func(value string) []Directive {
+	return func(value string) []Directive {
+		d := Directive{Name: name, Sources: []Source{}}
+
+		for _, origin := range strings.Split(value, sep) {
+			origin = strings.TrimSpace(origin)
+			if origin != "" && origin != "*" && origin != "null" {
+				d.Sources = append(d.Sources, ParseSource(origin))
+			}
+		}
+
+		return []Directive{d}
+	}
+}
+
+// extractLink returns the preconnect and dns-prefetch targets of a Link
+// header (e.g. <https://cdn.example.com>; rel=preconnect; crossorigin).
+func extractLink(value string) []Directive {
+	result := []Directive{}
+
+	for _, link := range splitOutside(value, ',', '<', '>') {
+		target, params, _ := strings.Cut(strings.TrimSpace(link), ";")
+		target = strings.TrimSpace(target)
+
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		s := ParseSource(strings.Trim(target, "<>"))
+
+		for _, param := range strings.Split(params, ";") {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(key), "rel") {
+				continue
+			}
+
+			for _, rel := range strings.Fields(strings.ToLower(strings.Trim(strings.TrimSpace(val), `"`))) {
+				if rel == "preconnect" || rel == "dns-prefetch" {
+					result = append(result, Directive{Name: rel, Sources: []Source{s}})
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// extractAltSvc returns the alternative hosts of an Alt-Svc header
+// (e.g. h3="alt.example.com:443"; ma=86400). Alternatives on the
+// same host (e.g. h3=":443") are ignored.
+func extractAltSvc(value string) []Directive {
+	result := []Directive{}
+
+	for _, alt := range strings.Split(value, ",") {
+		alt, _, _ = strings.Cut(alt, ";")
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(alt), "=")
+
+		if !ok {
+			continue
+		}
+
+		host, _, err := net.SplitHostPort(strings.Trim(strings.TrimSpace(authority), `"`))
+		if err != nil || host == "" {
+			continue
+		}
+
+		result = append(result, Directive{Name: strings.TrimSpace(protocol), Sources: []Source{ParseSource(host)}})
+	}
+
+	return result
+}
+
+// extractPermissionsPolicy returns the origins allowed for each feature
+// of a Permissions-Policy header (e.g. geolocation=(self "https://a.com")).
+func extractPermissionsPolicy(value string) []Directive {
+	result := []Directive{}
+
+	for _, member := range strings.Split(value, ",") {
+		feature, allowlist, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+
+		d := Directive{Name: strings.TrimSpace(feature), Sources: []Source{}}
+		allowlist, _, _ = strings.Cut(allowlist, ";")
+
+		for _, item := range strings.Fields(strings.Trim(strings.TrimSpace(allowlist), "()")) {
+			// Origins are strings, self and * are tokens.
+			if strings.HasPrefix(item, `"`) {
+				d.Sources = append(d.Sources, ParseSource(strings.Trim(item, `"`)))
+			}
+		}
+
+		if len(d.Sources) != 0 {
+			result = append(result, d)
+		}
+	}
+
+	return result
+}
+
+// extractCookieDomain returns the Domain attribute of a Set-Cookie header.
+func extractCookieDomain(value string) []Directive {
+	cookie, err := http.ParseSetCookie(value)
+	if err != nil || cookie.Domain == "" {
+		return []Directive{}
+	}
+
+	return []Directive{{Name: "domain", Sources: []Source{ParseSource(strings.TrimPrefix(cookie.Domain, "."))}}}
+}
+
+// splitOutside splits s around sep, ignoring the separators
+// found between the open and the close characters.
+func splitOutside(s string, sep, open, closing rune) []string {
+	result := []string{}
+	depth, start := 0, 0
+
+	for i, c := range s {
+		switch c {
+		case open:
+			depth++
+		case closing:
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				result = append(result, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(result, s[start:])
+}
@@ -0,0 +1,103 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderFindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		values []string
+		want   []string
+	}{
+		{
+			name:   "ACAO",
+			header: csprecon.HeaderACAO,
+			values: []string{"https://app.example.com", "*", "null"},
+			want:   []string{"app.example.com allow-origin"},
+		},
+		{
+			name:   "Link",
+			header: csprecon.HeaderLink,
+			values: []string{
+				`<https://fonts.example.com>; rel=preconnect; crossorigin, </style.css>; rel=preload; as=style`,
+				`<https://cdn.example.com/a,b>; rel="dns-prefetch preconnect", <https://next.example.com>; rel=next`,
+			},
+			want: []string{
+				"fonts.example.com preconnect",
+				"cdn.example.com dns-prefetch",
+				"cdn.example.com preconnect",
+			},
+		},
+		{
+			name:   "Alt-Svc",
+			header: csprecon.HeaderAltSvc,
+			values: []string{`h3=":443"; ma=86400, h3-29="alt.example.com:443"; ma=86400`, "clear"},
+			want:   []string{"alt.example.com h3-29"},
+		},
+		{
+			name:   "Permissions-Policy",
+			header: csprecon.HeaderPermissionsPolicy,
+			values: []string{`geolocation=(self "https://maps.example.com"), camera=(), fullscreen=*, payment=("https://pay.example.com" "https://*.psp.example.com")`},
+			want: []string{
+				"maps.example.com geolocation",
+				"pay.example.com payment",
+				"*.psp.example.com payment",
+			},
+		},
+		{
+			name:   "Set-Cookie",
+			header: csprecon.HeaderSetCookie,
+			values: []string{"sid=abc; Domain=.auth.example.com; Path=/; Secure", "lang=it; Path=/"},
+			want:   []string{"auth.example.com domain"},
+		},
+		{
+			name:   "Timing-Allow-Origin",
+			header: csprecon.HeaderTimingAllowOrigin,
+			values: []string{"https://a.example.com, https://b.example.com"},
+			want:   []string{"a.example.com allow-origin", "b.example.com allow-origin"},
+		},
+	}
+
+	extractors := csprecon.DefaultHeaderExtractors()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range tt.values {
+				header.Add(tt.header, value)
+			}
+
+			got := []string{}
+			for _, f := range csprecon.HeaderFindings(header, extractors, csprecon.CompileRegex(csprecon.DomainRegex)) {
+				require.Equal(t, tt.header, f.Origin)
+				got = append(got, f.Domain+" "+f.Directive)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHeaderExtractor(t *testing.T) {
+	extractor := csprecon.DefaultHeaderExtractors()[0]
+	require.Equal(t, "access-control-allow-origin", extractor.Name())
+
+	// Header findings are filtered by their own header name only.
+	header := http.Header{csprecon.HeaderACAO: {"https://app.example.com"}}
+	findings, err := extractor.Extract(&csprecon.ExtractorInput{Header: header})
+	require.NoError(t, err)
+	require.Len(t, csprecon.FilterFindingsByOrigin(findings, []string{"access-control-allow-origin"}), 1)
+	require.Empty(t, csprecon.FilterFindingsByOrigin(findings, []string{"header", "meta", "enforce"}))
+}
@@ -0,0 +1,41 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"github.com/projectdiscovery/gologger"
+)
+
+// Logger receives the messages logged by a Runner and by CheckCSP.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// GologgerLogger logs with the default gologger logger,
+// whose level is configured by the command line options.
+type GologgerLogger struct{}
+
+// Debugf logs a debug message.
+func (GologgerLogger) Debugf(format string, args ...any) {
+	gologger.Debug().Msgf(format, args...)
+}
+
+// Errorf logs an error message.
+func (GologgerLogger) Errorf(format string, args ...any) {
+	gologger.Error().Msgf(format, args...)
+}
+
+// NopLogger discards every message.
+type NopLogger struct{}
+
+// Debugf discards the message.
+func (NopLogger) Debugf(string, ...any) {}
+
+// Errorf discards the message.
+func (NopLogger) Errorf(string, ...any) {}
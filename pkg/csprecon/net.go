@@ -7,13 +7,16 @@ This repository is under MIT License https://github.com/edoardottt/csprecon/blob
 package csprecon
 
 import (
+	"fmt"
 	"net"
 	"net/url"
 	"regexp"
 	"strings"
 
 	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/edoardottt/golazy"
 	"github.com/projectdiscovery/mapcidr"
+	"golang.org/x/net/publicsuffix"
 )
 
 // CompileRegex.
@@ -42,8 +45,31 @@ func DomainOk(input string, domains []string) bool {
 	return false
 }
 
-// PrepareURL takes as input a string and prepares
-// the input URL in order to get the favicon icon.
+// RegistrableDomain returns the registrable domain (eTLD+1) of the input
+// host using the embedded Public Suffix List, e.g. a.b.example.co.uk
+// returns example.co.uk. Leading wildcard labels are ignored.
+func RegistrableDomain(host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return "", fmt.Errorf("%w: %s", input.ErrNoRegistrableDomain, host)
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimPrefix(host, "*."))
+	if err != nil {
+		return "", err
+	}
+
+	if strings.Contains(domain, "*") {
+		return "", fmt.Errorf("%w: %s", input.ErrNoRegistrableDomain, host)
+	}
+
+	return domain, nil
+}
+
+// PrepareURL takes as input a string and prepares the input URL
+// to be requested: the path and the query string are preserved,
+// the userinfo and the fragment are stripped.
 func PrepareURL(inputURL string) (string, error) {
 	if len(inputURL) < MinURLLength {
 		return "", input.ErrMalformedURL
@@ -55,10 +81,69 @@ func PrepareURL(inputURL string) (string, error) {
 
 	u, err := url.Parse(inputURL)
 	if err != nil {
-		return "", err
+		// The parse error quotes the input, credentials included.
+		return "", input.ErrMalformedURL
+	}
+
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String(), nil
+}
+
+// ProbeURLs returns the URLs to check for the input: bare hosts and IPs
+// are combined with every probe, other inputs (URLs with a scheme, hosts
+// with a port) are handled by PrepareURL.
+func ProbeURLs(inputURL string, probes []input.Probe) ([]string, error) {
+	host, path := inputURL, ""
+
+	if ip := net.ParseIP(inputURL); ip != nil {
+		if ip.To4() == nil {
+			host = "[" + ip.String() + "]"
+		}
+	} else {
+		if i := strings.IndexAny(inputURL, "/?#"); i != -1 {
+			host, path = inputURL[:i], inputURL[i:]
+		}
+
+		if _, _, err := net.SplitHostPort(host); err == nil || strings.Contains(inputURL, "://") {
+			probes = nil
+		}
+	}
+
+	if len(probes) == 0 {
+		targetURL, err := PrepareURL(inputURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{targetURL}, nil
+	}
+
+	if len(inputURL) < MinURLLength {
+		return nil, input.ErrMalformedURL
+	}
+
+	result := []string{}
+
+	for _, p := range probes {
+		probeURL := p.Scheme + "://" + net.JoinHostPort(strings.Trim(host, "[]"), p.Port) + path
+
+		if (p.Scheme == input.SchemeHTTP && p.Port == input.DefaultHTTPPort) ||
+			(p.Scheme == input.SchemeHTTPS && p.Port == input.DefaultHTTPSPort) {
+			probeURL = p.Scheme + "://" + host + path
+		}
+
+		targetURL, err := PrepareURL(probeURL)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, targetURL)
 	}
 
-	return u.Scheme + "://" + u.Host + u.Path, nil
+	return golazy.RemoveDuplicateValues(result), nil
 }
 
 func handleCIDRInput(inputCidr string) ([]string, error) {
@@ -0,0 +1,290 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DispositionEnforce    = "enforce"
+	DispositionReportOnly = "report-only"
+	OriginFilterHeader    = "header"
+	OriginFilterLegacy    = "legacy"
+)
+
+// SourceType is the class of a CSP source expression.
+type SourceType int
+
+const (
+	SourceOther SourceType = iota
+	SourceKeyword
+	SourceNonce
+	SourceHash
+	SourceScheme
+	SourceHost
+)
+
+// nolint: gochecknoglobals
+var sourceTypeNames = map[SourceType]string{
+	SourceOther:   "other",
+	SourceKeyword: "keyword",
+	SourceNonce:   "nonce",
+	SourceHash:    "hash",
+	SourceScheme:  "scheme",
+	SourceHost:    "host",
+}
+
+// String returns the name of the source type.
+func (t SourceType) String() string {
+	if name, ok := sourceTypeNames[t]; ok {
+		return name
+	}
+
+	return sourceTypeNames[SourceOther]
+}
+
+// Source is a single classified source expression of a directive.
+// Scheme, Host, Port and Path are set for host sources (Scheme also for
+// scheme sources), Value holds the keyword, the nonce or the hash value
+// and Algorithm the hash algorithm.
+type Source struct {
+	Expression string
+	Type       SourceType
+	Scheme     string
+	Host       string
+	Port       string
+	Path       string
+	Value      string
+	Algorithm  string
+}
+
+// Directive is a CSP directive with its source expressions.
+type Directive struct {
+	Name    string
+	Sources []Source
+}
+
+// Policy is a parsed Content Security Policy.
+// Directives are kept in the order they appear in the raw policy,
+// Origin is the header name or meta tag the policy was delivered with.
+type Policy struct {
+	Origin     string
+	Directives []Directive
+}
+
+// nolint: gochecknoglobals
+var (
+	// directives whose value is not a source list.
+	nonSourceListDirectives = map[string]struct{}{
+		"sandbox":                   {},
+		"report-to":                 {},
+		"trusted-types":             {},
+		"require-trusted-types-for": {},
+		"upgrade-insecure-requests": {},
+		"block-all-mixed-content":   {},
+		"webrtc":                    {},
+		"plugin-types":              {},
+		"require-sri-for":           {},
+		"referrer":                  {},
+	}
+	schemeRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+\-.]*$`)
+	hashRegex   = regexp.MustCompile(`^'((?i:sha256|sha384|sha512))-([a-zA-Z0-9+/\-_]+={0,2})'$`)
+	nonceRegex  = regexp.MustCompile(`^'(?i:nonce)-([a-zA-Z0-9+/\-_]+={0,2})'$`)
+	hostRegex   = regexp.MustCompile(`^(?:\*|(?:\*\.)?[a-zA-Z0-9\-_]+(?:\.[a-zA-Z0-9\-_*]+)*\.?|\[[0-9a-fA-F:.]+\])$`)
+	portRegex   = regexp.MustCompile(`^(?:[0-9]+|\*)$`)
+)
+
+// ParsePolicyList parses a serialized CSP policy list,
+// i.e. one or more policies separated by commas.
+func ParsePolicyList(input string) []Policy {
+	result := []Policy{}
+
+	for _, raw := range strings.Split(input, ",") {
+		if policy := ParsePolicy(raw); len(policy.Directives) != 0 {
+			result = append(result, policy)
+		}
+	}
+
+	return result
+}
+
+// ParsePolicy parses a single serialized CSP policy.
+// Empty directives are skipped and, as browsers do, only the first
+// occurrence of a directive is kept.
+func ParsePolicy(input string) Policy {
+	policy := Policy{Directives: []Directive{}}
+	seen := map[string]struct{}{}
+
+	for _, token := range strings.Split(input, ";") {
+		fields := strings.Fields(token)
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(fields[0])
+		if _, ok := seen[name]; ok {
+			continue
+		}
+
+		seen[name] = struct{}{}
+
+		directive := Directive{Name: name, Sources: []Source{}}
+
+		for _, value := range fields[1:] {
+			if _, ok := nonSourceListDirectives[name]; ok {
+				directive.Sources = append(directive.Sources, Source{Expression: value, Type: SourceOther})
+			} else {
+				directive.Sources = append(directive.Sources, ParseSource(value))
+			}
+		}
+
+		policy.Directives = append(policy.Directives, directive)
+	}
+
+	return policy
+}
+
+// ParseSource classifies a single source expression.
+func ParseSource(expression string) Source {
+	source := Source{Expression: expression, Type: SourceOther}
+
+	switch {
+	case nonceRegex.MatchString(expression):
+		source.Type = SourceNonce
+		source.Value = nonceRegex.FindStringSubmatch(expression)[1]
+	case hashRegex.MatchString(expression):
+		match := hashRegex.FindStringSubmatch(expression)
+		source.Type = SourceHash
+		source.Algorithm = strings.ToLower(match[1])
+		source.Value = match[2]
+	case len(expression) > 2 && strings.HasPrefix(expression, "'") && strings.HasSuffix(expression, "'"):
+		source.Type = SourceKeyword
+		source.Value = strings.ToLower(expression[1 : len(expression)-1])
+	case strings.HasSuffix(expression, ":") && schemeRegex.MatchString(expression[:len(expression)-1]):
+		source.Type = SourceScheme
+		source.Scheme = strings.ToLower(expression[:len(expression)-1])
+	default:
+		if hostSource, ok := parseHostSource(expression); ok {
+			return hostSource
+		}
+	}
+
+	return source
+}
+
+// parseHostSource splits a host-source expression into
+// scheme, host, port and path.
+func parseHostSource(expression string) (Source, bool) {
+	source := Source{Expression: expression, Type: SourceHost}
+	rest := expression
+
+	if scheme, after, ok := strings.Cut(rest, "://"); ok {
+		if !schemeRegex.MatchString(scheme) {
+			return Source{}, false
+		}
+
+		source.Scheme = strings.ToLower(scheme)
+		rest = after
+	}
+
+	// Query and fragment are not part of a host-source, but they can
+	// be found in report-uri values.
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	if i := strings.Index(rest, "/"); i >= 0 {
+		source.Path = rest[i:]
+		rest = rest[:i]
+	}
+
+	host, port, hasPort := splitHostPort(rest)
+	if !hostRegex.MatchString(host) || (hasPort && !portRegex.MatchString(port)) {
+		return Source{}, false
+	}
+
+	source.Host = strings.ToLower(host)
+	source.Port = port
+
+	return source, true
+}
+
+// splitHostPort splits the host and the optional port,
+// handling bracketed IPv6 literals.
+func splitHostPort(input string) (host, port string, hasPort bool) {
+	if strings.HasPrefix(input, "[") {
+		if i := strings.Index(input, "]"); i >= 0 {
+			port, hasPort = strings.CutPrefix(input[i+1:], ":")
+			if !hasPort && input[i+1:] != "" {
+				return input, "", false
+			}
+
+			return input[:i+1], port, hasPort
+		}
+	}
+
+	return strings.Cut(input, ":")
+}
+
+// Get returns the directive with the given name, if present.
+func (p Policy) Get(name string) (Directive, bool) {
+	name = strings.ToLower(name)
+
+	for _, d := range p.Directives {
+		if d.Name == name {
+			return d, true
+		}
+	}
+
+	return Directive{}, false
+}
+
+// ReportOnly reports whether the policy is delivered
+// in report-only mode, i.e. it's not enforced.
+func (p Policy) ReportOnly() bool {
+	return p.Origin == HeaderCSPReportOnly
+}
+
+// OriginDisposition returns the disposition of the policies delivered
+// with the origin: enforce or report-only. It returns an empty
+// string for origins not delivering policies.
+func OriginDisposition(origin string) string {
+	switch origin {
+	case HeaderCSPReportOnly:
+		return DispositionReportOnly
+	case HeaderCSP, HeaderXCSP, HeaderXWebKitCSP, OriginMeta:
+		return DispositionEnforce
+	default:
+		return ""
+	}
+}
+
+// OriginOk checks origin filtering based on input. A filter matches an
+// origin name (case-insensitive, e.g. a header name, meta or tls-san),
+// any CSP header (header), legacy headers (legacy) or a disposition
+// (enforce, report-only).
+func OriginOk(origin string, filters []string) bool {
+	if len(origin) == 0 || len(filters) == 0 {
+		return false
+	}
+
+	for _, filter := range filters {
+		filter = strings.ToLower(strings.TrimSpace(filter))
+
+		switch {
+		case filter == strings.ToLower(origin),
+			filter == OriginDisposition(origin),
+			filter == OriginFilterHeader && OriginDisposition(origin) != "" && origin != OriginMeta,
+			filter == OriginFilterLegacy && (origin == HeaderXCSP || origin == HeaderXWebKitCSP):
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,199 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  csprecon.Policy
+	}{
+		{
+			name:  "empty",
+			input: "",
+			want:  csprecon.Policy{Directives: []csprecon.Directive{}},
+		},
+		{
+			name:  "empty directives",
+			input: " ; ;; ",
+			want:  csprecon.Policy{Directives: []csprecon.Directive{}},
+		},
+		{
+			name:  "directive without value",
+			input: "upgrade-insecure-requests",
+			want: csprecon.Policy{Directives: []csprecon.Directive{
+				{Name: "upgrade-insecure-requests", Sources: []csprecon.Source{}},
+			}},
+		},
+		{
+			name:  "order and duplicates",
+			input: "Script-Src 'self' https://a.example.com; img-src data:; script-src https://b.example.com",
+			want: csprecon.Policy{Directives: []csprecon.Directive{
+				{Name: "script-src", Sources: []csprecon.Source{
+					{Expression: "'self'", Type: csprecon.SourceKeyword, Value: "self"},
+					{Expression: "https://a.example.com", Type: csprecon.SourceHost, Scheme: "https", Host: "a.example.com"},
+				}},
+				{Name: "img-src", Sources: []csprecon.Source{
+					{Expression: "data:", Type: csprecon.SourceScheme, Scheme: "data"},
+				}},
+			}},
+		},
+		{
+			name:  "non source list directive",
+			input: "sandbox allow-scripts allow-forms",
+			want: csprecon.Policy{Directives: []csprecon.Directive{
+				{Name: "sandbox", Sources: []csprecon.Source{
+					{Expression: "allow-scripts", Type: csprecon.SourceOther},
+					{Expression: "allow-forms", Type: csprecon.SourceOther},
+				}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := csprecon.ParsePolicy(tt.input)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParsePolicyList(t *testing.T) {
+	got := csprecon.ParsePolicyList("default-src 'self', script-src https://cdn.example.com; object-src 'none' ,")
+	require.Len(t, got, 2)
+	require.Len(t, got[0].Directives, 1)
+	require.Len(t, got[1].Directives, 2)
+
+	d, ok := got[1].Get("OBJECT-SRC")
+	require.True(t, ok)
+	require.Equal(t, "object-src", d.Name)
+
+	_, ok = got[0].Get("script-src")
+	require.False(t, ok)
+}
+
+func TestParseSource(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  csprecon.Source
+	}{
+		{
+			name:  "keyword",
+			input: "'Strict-Dynamic'",
+			want:  csprecon.Source{Expression: "'Strict-Dynamic'", Type: csprecon.SourceKeyword, Value: "strict-dynamic"},
+		},
+		{
+			name:  "nonce",
+			input: "'nonce-rAnd0m=='",
+			want:  csprecon.Source{Expression: "'nonce-rAnd0m=='", Type: csprecon.SourceNonce, Value: "rAnd0m=="},
+		},
+		{
+			name:  "hash",
+			input: "'sha256-B2yPHKaXnvFWtRChIbabYmUBFZdVfKKXHbWtWidDVF8='",
+			want: csprecon.Source{
+				Expression: "'sha256-B2yPHKaXnvFWtRChIbabYmUBFZdVfKKXHbWtWidDVF8='",
+				Type:       csprecon.SourceHash,
+				Value:      "B2yPHKaXnvFWtRChIbabYmUBFZdVfKKXHbWtWidDVF8=",
+				Algorithm:  "sha256",
+			},
+		},
+		{
+			name:  "scheme",
+			input: "wss:",
+			want:  csprecon.Source{Expression: "wss:", Type: csprecon.SourceScheme, Scheme: "wss"},
+		},
+		{
+			name:  "wildcard",
+			input: "*",
+			want:  csprecon.Source{Expression: "*", Type: csprecon.SourceHost, Host: "*"},
+		},
+		{
+			name:  "host only",
+			input: "cdn.Example.com",
+			want:  csprecon.Source{Expression: "cdn.Example.com", Type: csprecon.SourceHost, Host: "cdn.example.com"},
+		},
+		{
+			name:  "host with scheme, port and path",
+			input: "https://*.example.com:8443/api/",
+			want: csprecon.Source{
+				Expression: "https://*.example.com:8443/api/",
+				Type:       csprecon.SourceHost,
+				Scheme:     "https",
+				Host:       "*.example.com",
+				Port:       "8443",
+				Path:       "/api/",
+			},
+		},
+		{
+			name:  "host with wildcard port",
+			input: "admin.example.com:*",
+			want:  csprecon.Source{Expression: "admin.example.com:*", Type: csprecon.SourceHost, Host: "admin.example.com", Port: "*"},
+		},
+		{
+			name:  "report uri",
+			input: "https://example.com/csp?id=1",
+			want: csprecon.Source{
+				Expression: "https://example.com/csp?id=1",
+				Type:       csprecon.SourceHost,
+				Scheme:     "https",
+				Host:       "example.com",
+				Path:       "/csp",
+			},
+		},
+		{
+			name:  "ipv4 with port",
+			input: "http://10.1.2.3:8080",
+			want:  csprecon.Source{Expression: "http://10.1.2.3:8080", Type: csprecon.SourceHost, Scheme: "http", Host: "10.1.2.3", Port: "8080"},
+		},
+		{
+			name:  "ipv6",
+			input: "[2001:db8::1]",
+			want:  csprecon.Source{Expression: "[2001:db8::1]", Type: csprecon.SourceHost, Host: "[2001:db8::1]"},
+		},
+		{
+			name:  "ipv6 with scheme, port and path",
+			input: "https://[::1]:8443/admin",
+			want: csprecon.Source{
+				Expression: "https://[::1]:8443/admin",
+				Type:       csprecon.SourceHost,
+				Scheme:     "https",
+				Host:       "[::1]",
+				Port:       "8443",
+				Path:       "/admin",
+			},
+		},
+		{
+			name:  "invalid ipv6",
+			input: "[::1]8443",
+			want:  csprecon.Source{Expression: "[::1]8443", Type: csprecon.SourceOther},
+		},
+		{
+			name:  "invalid port",
+			input: "example.com:80a",
+			want:  csprecon.Source{Expression: "example.com:80a", Type: csprecon.SourceOther},
+		},
+		{
+			name:  "invalid",
+			input: "https://exa mple.com/\"",
+			want:  csprecon.Source{Expression: "https://exa mple.com/\"", Type: csprecon.SourceOther},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := csprecon.ParseSource(tt.input)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
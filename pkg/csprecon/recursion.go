@@ -0,0 +1,151 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// frontier keeps track of the inputs queued during a run: their
+// depth (0 for the inputs given by the user, n for the hosts found
+// scanning an input of depth n-1) and how many of them are still
+// to be scanned. Discovered hosts are queued once per run.
+type frontier struct {
+	mutex   sync.Mutex
+	depths  map[string]int
+	pending sync.WaitGroup
+}
+
+func newFrontier() *frontier {
+	return &frontier{depths: map[string]int{}}
+}
+
+// add marks the host of the input as queued at the given depth.
+// It returns false if the host was already queued.
+func (f *frontier) add(value string, depth int) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	host := InputHost(value)
+	if _, ok := f.depths[host]; ok {
+		return false
+	}
+
+	f.depths[host] = depth
+
+	return true
+}
+
+// addInput marks the host of a user input as queued at depth 0, even if
+// it was discovered first. It returns false if the host was already
+// queued by the discovery: it's scanned (or was) only once.
+func (f *frontier) addInput(value string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	host := InputHost(value)
+	depth, ok := f.depths[host]
+	f.depths[host] = 0
+
+	return !ok || depth == 0
+}
+
+// depth returns the depth of the input, 0 if it was not queued.
+func (f *frontier) depth(value string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.depths[InputHost(value)]
+}
+
+// InputHost returns the lowercase host of an input (URL, host, IP).
+func InputHost(value string) string {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Hostname() == "" {
+		return strings.ToLower(value)
+	}
+
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// InScope reports whether a finding of the target can be scanned
+// recursively: it must be a concrete host belonging to the domains,
+// or to the registrable domain of the target when no domain is given.
+func InScope(f Finding, targetURL string, domains []string) bool {
+	if f.HostType != HostConcrete {
+		return false
+	}
+
+	if len(domains) != 0 {
+		return DomainOk(f.Domain, domains)
+	}
+
+	target, err := RegistrableDomain(InputHost(targetURL))
+	if err != nil {
+		return false
+	}
+
+	domain, err := RegistrableDomain(f.Domain)
+
+	return err == nil && domain == target
+}
+
+// enqueue sends the user input to the workers, unless its host was
+// already queued by the discovery. It returns false if the context
+// is done before the input is sent.
+func (r *Runner) enqueue(ctx context.Context, value string) bool {
+	if !r.frontier.addInput(value) {
+		r.Logger.Debugf("Skipping %s, already queued by the recursive discovery", value)
+
+		return true
+	}
+
+	r.frontier.pending.Add(1)
+
+	select {
+	case r.Input <- value:
+		return true
+	case <-ctx.Done():
+		r.frontier.pending.Done()
+
+		return false
+	}
+}
+
+// discover queues the in-scope findings of the target, found at the
+// given depth, not queued yet. The findings are sent asynchronously
+// so that workers never block on a full input channel.
+func (r *Runner) discover(ctx context.Context, findings []Finding, targetURL string, depth int) {
+	if depth >= r.Options.RecursionDepth {
+		return
+	}
+
+	for _, f := range findings {
+		if !InScope(f, targetURL, r.Options.Domain) || !r.frontier.add(f.Domain, depth+1) {
+			continue
+		}
+
+		r.Logger.Debugf("Queueing %s found in %s (depth %d)", f.Domain, targetURL, depth+1)
+
+		r.frontier.pending.Add(1)
+
+		go func(host string) {
+			select {
+			case r.Input <- host:
+			case <-ctx.Done():
+				r.frontier.pending.Done()
+			}
+		}(f.Domain)
+	}
+}
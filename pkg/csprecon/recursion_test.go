@@ -0,0 +1,192 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/edoardottt/csprecon/pkg/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputHost(t *testing.T) {
+	tests := map[string]string{
+		"Example.com":                 "example.com",
+		"example.com.":                "example.com",
+		"https://www.example.com/a?b": "www.example.com",
+		"example.com:8443/path":       "example.com",
+		"10.0.0.1":                    "10.0.0.1",
+		"http://[::1]:8080":           "::1",
+	}
+
+	for value, want := range tests {
+		require.Equal(t, want, csprecon.InputHost(value), value)
+	}
+}
+
+func TestInScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		finding csprecon.Finding
+		domains []string
+		want    bool
+	}{
+		{
+			name:    "same registrable domain",
+			finding: csprecon.Finding{Domain: "cdn.example.com", HostType: csprecon.HostConcrete},
+			want:    true,
+		},
+		{
+			name:    "other registrable domain",
+			finding: csprecon.Finding{Domain: "cdn.example.org", HostType: csprecon.HostConcrete},
+			want:    false,
+		},
+		{
+			name:    "wildcard",
+			finding: csprecon.Finding{Domain: "*.example.com", HostType: csprecon.HostWildcard},
+			want:    false,
+		},
+		{
+			name:    "domain filter",
+			finding: csprecon.Finding{Domain: "cdn.example.org", HostType: csprecon.HostConcrete},
+			domains: []string{"example.org"},
+			want:    true,
+		},
+		{
+			name:    "outside domain filter",
+			finding: csprecon.Finding{Domain: "cdn.example.com", HostType: csprecon.HostConcrete},
+			domains: []string{"example.org"},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, csprecon.InScope(tt.finding, "https://www.example.com/login", tt.domains))
+		})
+	}
+}
+
+func TestRunRecursive(t *testing.T) {
+	// Every host is served by the test server: a.test trusts b.a.test,
+	// which trusts c.a.test, which trusts d.a.test.
+	policies := map[string]string{
+		"a.test":   "script-src b.a.test a.test other.test *.a.test",
+		"b.a.test": "script-src c.a.test a.test",
+		"c.a.test": "script-src d.a.test",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(csprecon.HeaderCSP, policies[r.Host])
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		depth int
+		want  map[string]int
+	}{
+		{depth: 0, want: map[string]int{"http://a.test": 0}},
+		{depth: 2, want: map[string]int{"http://a.test": 0, "http://b.a.test": 1, "http://c.a.test": 2}},
+	}
+
+	for _, tt := range tests {
+		out := &bytes.Buffer{}
+		options := input.DefaultOptions()
+		options.Input = "a.test"
+		options.Concurrency = 2
+		options.JSON = true
+		options.Silent = true
+		options.RecursionDepth = tt.depth
+		options.Output = out
+
+		runner := newTestRunner(t, options, server)
+		require.NoError(t, runner.Run(context.Background()))
+
+		got := map[string]int{}
+		scanner := bufio.NewScanner(out)
+
+		for scanner.Scan() {
+			var data output.JSONData
+
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &data))
+
+			got[data.URL] = data.Depth
+		}
+
+		require.Equal(t, tt.want, got)
+	}
+}
+
+func TestRunRecursiveLateInput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "a.test" {
+			w.Header().Set(csprecon.HeaderCSP, "script-src b.a.test")
+		}
+	}))
+	defer server.Close()
+
+	stdin, writer := io.Pipe()
+	options := input.DefaultOptions()
+	options.Stdin = stdin
+	options.RecursionDepth = 1
+
+	runner := newTestRunner(t, options, server)
+	runner.Logger = csprecon.NopLogger{}
+
+	got := map[string][]int{}
+	discovered := make(chan struct{})
+	runner.OnResult = func(res csprecon.Result) {
+		got[res.URL] = append(got[res.URL], res.Depth)
+		if res.URL == "http://b.a.test" && len(got[res.URL]) == 1 {
+			close(discovered)
+		}
+	}
+
+	go func() {
+		_, _ = io.WriteString(writer, "a.test\n")
+		<-discovered
+		// b.a.test is given by the user after being discovered.
+		_, _ = io.WriteString(writer, "b.a.test\n")
+		writer.Close()
+	}()
+
+	require.NoError(t, runner.Run(context.Background()))
+	require.Equal(t, map[string][]int{"http://a.test": {0}, "http://b.a.test": {1}}, got)
+}
+
+// newTestRunner returns a runner sending the requests
+// of every host to the test server.
+func newTestRunner(t *testing.T, options *input.Options, server *httptest.Server) *csprecon.Runner {
+	t.Helper()
+
+	runner, err := csprecon.New(options)
+	require.NoError(t, err)
+
+	runner.Client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return runner
+}
@@ -0,0 +1,211 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	HeaderReportTo           = "Report-To"
+	HeaderReportingEndpoints = "Reporting-Endpoints"
+	DirectiveReportURI       = "report-uri"
+	DirectiveReportTo        = "report-to"
+	TypeReportEndpoint       = "report-endpoint"
+	// defaultReportToGroup is the group name used by Report-To
+	// entries without one.
+	defaultReportToGroup = "default"
+)
+
+// ReportEndpoint is a CSP reporting endpoint. Group is set for report-to
+// endpoints, URL is empty when the group can't be resolved.
+type ReportEndpoint struct {
+	Directive string
+	Origin    string
+	Group     string
+	URL       string
+}
+
+// reportToEntry is a single group of the Report-To header.
+type reportToEntry struct {
+	Group     string `json:"group"`
+	Endpoints []struct {
+		URL string `json:"url"`
+	} `json:"endpoints"`
+}
+
+// ParseReportingEndpoints parses the Reporting-Endpoints header
+// (e.g. csp="https://example.com/csp", nel="/nel") and returns
+// the endpoint URL of each group.
+func ParseReportingEndpoints(input string) map[string][]string {
+	result := map[string][]string{}
+
+	for _, member := range strings.Split(input, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+
+		// Drop structured field parameters, if any.
+		value, _, _ = strings.Cut(value, ";")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		if name != "" && value != "" {
+			result[name] = append(result[name], value)
+		}
+	}
+
+	return result
+}
+
+// ParseReportTo parses the (JSON) Report-To header and returns
+// the endpoint URLs of each group.
+func ParseReportTo(input string) map[string][]string {
+	result := map[string][]string{}
+	entries := []reportToEntry{}
+
+	// Multiple groups are joined with commas, not wrapped in an array.
+	if err := json.Unmarshal([]byte("["+input+"]"), &entries); err != nil {
+		return result
+	}
+
+	for _, entry := range entries {
+		group := entry.Group
+		if group == "" {
+			group = defaultReportToGroup
+		}
+
+		for _, endpoint := range entry.Endpoints {
+			if endpoint.URL != "" {
+				result[group] = append(result[group], endpoint.URL)
+			}
+		}
+	}
+
+	return result
+}
+
+// ReportingGroups returns the reporting groups defined in the
+// Report-To and Reporting-Endpoints headers.
+func ReportingGroups(header http.Header) map[string][]string {
+	result := map[string][]string{}
+
+	for _, value := range header.Values(HeaderReportTo) {
+		for group, urls := range ParseReportTo(value) {
+			result[group] = append(result[group], urls...)
+		}
+	}
+
+	for _, value := range header.Values(HeaderReportingEndpoints) {
+		for group, urls := range ParseReportingEndpoints(value) {
+			result[group] = append(result[group], urls...)
+		}
+	}
+
+	return result
+}
+
+// ReportEndpoints returns the report-uri URLs and the report-to
+// groups of the policies, resolving the groups to their URLs.
+func ReportEndpoints(policies []Policy, groups map[string][]string) []ReportEndpoint {
+	result := []ReportEndpoint{}
+
+	for _, policy := range policies {
+		if d, ok := policy.Get(DirectiveReportURI); ok {
+			for _, s := range d.Sources {
+				result = append(result, ReportEndpoint{Directive: d.Name, Origin: policy.Origin, URL: s.Expression})
+			}
+		}
+
+		if d, ok := policy.Get(DirectiveReportTo); ok {
+			for _, s := range d.Sources {
+				urls := groups[s.Expression]
+				if len(urls) == 0 {
+					result = append(result, ReportEndpoint{Directive: d.Name, Origin: policy.Origin, Group: s.Expression})
+				}
+
+				for _, u := range urls {
+					result = append(result, ReportEndpoint{Directive: d.Name, Origin: policy.Origin, Group: s.Expression, URL: u})
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// ResolveEndpoints resolves relative endpoint URLs against the base URL.
+func ResolveEndpoints(endpoints []ReportEndpoint, base *url.URL) []ReportEndpoint {
+	for i, e := range endpoints {
+		if e.URL == "" {
+			continue
+		}
+
+		if u, err := base.Parse(e.URL); err == nil {
+			endpoints[i].URL = u.String()
+		}
+	}
+
+	return endpoints
+}
+
+// filterEndpoints returns the endpoints whose URL host belongs to the
+// domains and whose origin matches the origin filters, all of them if
+// there are no filters.
+func filterEndpoints(endpoints []ReportEndpoint, domains, origins []string) []ReportEndpoint {
+	if len(domains) == 0 && len(origins) == 0 {
+		return endpoints
+	}
+
+	result := []ReportEndpoint{}
+
+	for _, e := range endpoints {
+		if len(domains) != 0 && !DomainOk(endpointHost(e), domains) {
+			continue
+		}
+
+		if len(origins) == 0 || OriginOk(e.Origin, origins) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// endpointHost returns the lowercase host of the endpoint URL,
+// empty if the URL is missing or malformed.
+func endpointHost(e ReportEndpoint) string {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// EndpointFindings returns the findings matching the regex found in the
+// URLs of the report-to endpoints. report-uri endpoints are skipped as
+// they are already part of the policy findings.
+func EndpointFindings(endpoints []ReportEndpoint, r *regexp.Regexp) []Finding {
+	result := []Finding{}
+
+	for _, e := range endpoints {
+		if e.Directive != DirectiveReportTo || e.URL == "" {
+			continue
+		}
+
+		if s := ParseSource(e.URL); s.Type == SourceHost {
+			result = append(result, sourceFindings(s, e.Directive, e.Origin, r)...)
+		}
+	}
+
+	return result
+}
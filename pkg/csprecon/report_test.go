@@ -0,0 +1,90 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReportingEndpoints(t *testing.T) {
+	got := csprecon.ParseReportingEndpoints(`csp="https://csp.example.com/r", nel="/nel";x=1, broken`)
+	require.Equal(t, map[string][]string{
+		"csp": {"https://csp.example.com/r"},
+		"nel": {"/nel"},
+	}, got)
+}
+
+func TestParseReportTo(t *testing.T) {
+	got := csprecon.ParseReportTo(`{"group":"csp","max_age":10886400,"endpoints":[{"url":"https://a.example.com/csp"},` +
+		`{"url":"https://b.example.com/csp"}]}, {"max_age":1,"endpoints":[{"url":"https://c.example.com/"}]}`)
+	require.Equal(t, map[string][]string{
+		"csp":     {"https://a.example.com/csp", "https://b.example.com/csp"},
+		"default": {"https://c.example.com/"},
+	}, got)
+
+	require.Empty(t, csprecon.ParseReportTo(`{"group":`))
+}
+
+func TestReportEndpoints(t *testing.T) {
+	header := http.Header{}
+	header.Add(csprecon.HeaderReportTo, `{"group":"csp","endpoints":[{"url":"https://collector.example.com/csp"}]}`)
+	header.Add(csprecon.HeaderReportingEndpoints, `main="/reports"`)
+
+	policies := csprecon.ParsePolicyList("default-src 'self'; report-uri https://r.example.com/csp; report-to csp main missing")
+	for i := range policies {
+		policies[i].Origin = csprecon.HeaderCSP
+	}
+
+	base, err := url.Parse("https://www.example.com/login")
+	require.NoError(t, err)
+
+	got := csprecon.ResolveEndpoints(csprecon.ReportEndpoints(policies, csprecon.ReportingGroups(header)), base)
+	want := []csprecon.ReportEndpoint{
+		{Directive: "report-uri", Origin: csprecon.HeaderCSP, URL: "https://r.example.com/csp"},
+		{Directive: "report-to", Origin: csprecon.HeaderCSP, Group: "csp", URL: "https://collector.example.com/csp"},
+		{Directive: "report-to", Origin: csprecon.HeaderCSP, Group: "main", URL: "https://www.example.com/reports"},
+		{Directive: "report-to", Origin: csprecon.HeaderCSP, Group: "missing"},
+	}
+	require.Equal(t, want, got)
+
+	findings := csprecon.EndpointFindings(got, csprecon.CompileRegex(csprecon.DomainRegex))
+	require.Equal(t, []string{"collector.example.com", "www.example.com"}, csprecon.FindingsDomains(findings))
+}
+
+func TestRunReportEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(csprecon.HeaderCSP, "script-src 'self'; report-uri https://csp.a.test/r https://collector.other.test/r")
+	}))
+	defer server.Close()
+
+	options := input.DefaultOptions()
+	options.Input = "a.test"
+	options.ReportEndpoints = true
+	options.Domain = []string{"a.test"}
+
+	runner := newTestRunner(t, options, server)
+	runner.Logger = csprecon.NopLogger{}
+
+	got := []string{}
+	runner.OnResult = func(res csprecon.Result) {
+		for _, e := range res.ReportEndpoints {
+			got = append(got, e.URL)
+		}
+	}
+
+	require.NoError(t, runner.Run(context.Background()))
+	require.Equal(t, []string{"https://csp.a.test/r"}, got)
+}
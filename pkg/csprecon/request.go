@@ -0,0 +1,195 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edoardottt/csprecon/pkg/input"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	HeaderAuthorization = "Authorization"
+	HeaderCookie        = "Cookie"
+	Redacted            = "[REDACTED]"
+	// netscapeCookieFields is the number of tab separated
+	// fields of a line of a Netscape cookie file.
+	netscapeCookieFields = 7
+	httpOnlyPrefix       = "#HttpOnly_"
+)
+
+// nolint: gochecknoglobals
+var (
+	// sensitiveHeaders and the headers containing sensitiveWords are
+	// redacted from logs and dropped when a redirect leads to a
+	// different host.
+	sensitiveHeaders = map[string]struct{}{
+		HeaderAuthorization:   {},
+		HeaderCookie:          {},
+		"Proxy-Authorization": {},
+		"Set-Cookie":          {},
+	}
+	// sensitiveWords are the parts of a header name
+	// suggesting that its value is a secret.
+	sensitiveWords = []string{"token", "secret", "key", "session", "auth", "password", "csrf"}
+)
+
+// RequestHeaders returns the headers sent with every request:
+// the custom headers (Name: value) and the Authorization header
+// built from the basic auth credentials or the bearer token.
+func RequestHeaders(options *input.Options) (http.Header, error) {
+	result := http.Header{}
+
+	for i, h := range options.Headers {
+		// Don't print the header, it may contain secrets.
+		name, value, ok := input.ParseHeader(h)
+		if !ok {
+			return nil, fmt.Errorf("header #%d: %w", i+1, input.ErrInvalidValue)
+		}
+
+		result.Add(name, value)
+	}
+
+	switch {
+	case options.BasicAuth != "":
+		result.Set(HeaderAuthorization, "Basic "+base64.StdEncoding.EncodeToString([]byte(options.BasicAuth)))
+	case options.BearerToken != "":
+		result.Set(HeaderAuthorization, "Bearer "+options.BearerToken)
+	}
+
+	return result, nil
+}
+
+// RedactHeader returns the value of the header, or a placeholder
+// if the header is known or likely to contain a secret.
+func RedactHeader(name, value string) string {
+	if isSensitiveHeader(name) {
+		return Redacted
+	}
+
+	return value
+}
+
+// isSensitiveHeader reports whether the header is
+// known or likely to contain a secret.
+func isSensitiveHeader(name string) bool {
+	if _, ok := sensitiveHeaders[http.CanonicalHeaderKey(name)]; ok {
+		return true
+	}
+
+	for _, word := range sensitiveWords {
+		if strings.Contains(strings.ToLower(name), word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutSensitiveHeaders returns a copy of the headers
+// without the ones known or likely to contain a secret.
+func withoutSensitiveHeaders(header http.Header) http.Header {
+	result := header.Clone()
+
+	for name := range result {
+		if isSensitiveHeader(name) {
+			result.Del(name)
+		}
+	}
+
+	return result
+}
+
+// LoadCookieFile returns a cookie jar holding the
+// cookies of a Netscape cookie file (cookies.txt).
+func LoadCookieFile(path string) (http.CookieJar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ParseCookieFile(file)
+}
+
+// ParseCookieFile returns a cookie jar holding the cookies of a Netscape
+// cookie file. Each line has seven tab separated fields: domain, include
+// subdomains, path, secure, expiration (Unix time, 0 for session cookies),
+// name and value.
+func ParseCookieFile(r io.Reader) (http.CookieJar, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != netscapeCookieFields {
+			return nil, fmt.Errorf("cookie file line %d: %w", line, input.ErrInvalidValue)
+		}
+
+		expiration, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cookie file line %d: %w", line, input.ErrInvalidValue)
+		}
+
+		host := strings.TrimPrefix(fields[0], ".")
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+
+		// Host-only cookies have no Domain attribute.
+		if strings.EqualFold(fields[1], "TRUE") {
+			cookie.Domain = host
+		}
+
+		if expiration != 0 {
+			cookie.Expires = time.Unix(expiration, 0)
+		}
+
+		scheme := input.SchemeHTTP
+		if cookie.Secure {
+			scheme = input.SchemeHTTPS
+		}
+
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: cookie.Path}, []*http.Cookie{cookie})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return jar, nil
+}
@@ -0,0 +1,166 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+	"github.com/edoardottt/csprecon/pkg/input"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		options input.Options
+		want    http.Header
+		err     error
+	}{
+		{
+			name:    "no headers",
+			options: input.Options{},
+			want:    http.Header{},
+		},
+		{
+			name:    "custom headers",
+			options: input.Options{Headers: []string{"X-Api-Key: abc", "accept-language:it", "X-Forwarded-For: a, b"}},
+			want:    http.Header{"X-Api-Key": {"abc"}, "Accept-Language": {"it"}, "X-Forwarded-For": {"a, b"}},
+		},
+		{
+			name:    "basic auth",
+			options: input.Options{BasicAuth: "user:secret"},
+			want:    http.Header{"Authorization": {"Basic dXNlcjpzZWNyZXQ="}},
+		},
+		{
+			name:    "bearer token overrides the custom header",
+			options: input.Options{Headers: []string{"Authorization: old"}, BearerToken: "abc"},
+			want:    http.Header{"Authorization": {"Bearer abc"}},
+		},
+		{
+			name:    "malformed header",
+			options: input.Options{Headers: []string{"secret"}},
+			err:     input.ErrInvalidValue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := csprecon.RequestHeaders(&tt.options)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.want, got)
+
+			if err != nil {
+				require.NotContains(t, err.Error(), "secret")
+			}
+		})
+	}
+}
+
+func TestRedactHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "authorization", value: "Bearer abc", want: csprecon.Redacted},
+		{name: "Cookie", value: "a=b", want: csprecon.Redacted},
+		{name: "X-Api-Key", value: "abc", want: csprecon.Redacted},
+		{name: "X-CSRF-Token", value: "abc", want: csprecon.Redacted},
+		{name: "Accept-Language", value: "it", want: "it"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, csprecon.RedactHeader(tt.name, tt.value))
+		})
+	}
+}
+
+func TestParseCookieFile(t *testing.T) {
+	file := strings.Join([]string{
+		"# Netscape HTTP Cookie File",
+		"",
+		".example.com\tTRUE\t/\tFALSE\t0\tsession\tabc",
+		"#HttpOnly_app.example.com\tFALSE\t/admin\tTRUE\t0\tadmin\tdef",
+		"other.com\tFALSE\t/\tFALSE\t1\texpired\tghi",
+	}, "\n")
+
+	jar, err := csprecon.ParseCookieFile(strings.NewReader(file))
+	require.NoError(t, err)
+
+	cookies := func(rawURL string) []string {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+
+		result := []string{}
+		for _, c := range jar.Cookies(u) {
+			result = append(result, c.Name+"="+c.Value)
+		}
+
+		return result
+	}
+
+	require.Equal(t, []string{"session=abc"}, cookies("http://www.example.com/"))
+	require.Equal(t, []string{"session=abc"}, cookies("http://app.example.com/admin"))
+	require.ElementsMatch(t, []string{"session=abc", "admin=def"}, cookies("https://app.example.com/admin/users"))
+	require.Equal(t, []string{}, cookies("http://other.com/"))
+
+	_, err = csprecon.ParseCookieFile(strings.NewReader("example.com\tTRUE\t/\tFALSE\tnever\ta\tb"))
+	require.ErrorIs(t, err, input.ErrInvalidValue)
+}
+
+func TestCheckCSPHeaders(t *testing.T) {
+	received := map[string]http.Header{}
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received["other"] = r.Header.Clone()
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received[r.URL.Path] = r.Header.Clone()
+
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, strings.Replace(other.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	headers, err := csprecon.RequestHeaders(&input.Options{
+		Headers:     []string{"X-Tenant: acme", "Cookie: session=abc", "X-Api-Key: secret"},
+		BearerToken: "abc",
+	})
+	require.NoError(t, err)
+
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	_, err = csprecon.CheckCSP(server.URL+"/login", client, csprecon.RequestOptions{
+		UserAgent:    "csprecon",
+		Headers:      headers,
+		Redirects:    input.RedirectAll,
+		MaxRedirects: 1,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "acme", received["/login"].Get("X-Tenant"))
+	require.Equal(t, "Bearer abc", received["/login"].Get("Authorization"))
+	require.Equal(t, "session=abc", received["/login"].Get("Cookie"))
+	require.Equal(t, "secret", received["/login"].Get("X-Api-Key"))
+	require.Equal(t, "csprecon", received["/login"].Get("User-Agent"))
+
+	// Credentials are not sent to a different host.
+	require.Equal(t, "acme", received["other"].Get("X-Tenant"))
+	require.Empty(t, received["other"].Get("Authorization"))
+	require.Empty(t, received["other"].Get("Cookie"))
+	require.Empty(t, received["other"].Get("X-Api-Key"))
+}
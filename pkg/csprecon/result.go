@@ -0,0 +1,355 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/edoardottt/csprecon/pkg/input"
+	"github.com/edoardottt/csprecon/pkg/output"
+	"github.com/edoardottt/golazy"
+)
+
+// Result holds everything found for a single target, after the
+// filters of the options: the findings of every origin, the weaknesses,
+// bypasses, reporting endpoints and effective policy when requested,
+// the redirect chain and the recursion depth the target was found at.
+type Result struct {
+	URL             string
+	Policies        []Policy
+	Findings        []Finding
+	Weaknesses      []Weakness
+	Bypasses        []Bypass
+	UADiffs         []PolicyDifference
+	ReportEndpoints []ReportEndpoint
+	Effective       *EffectivePolicy
+	Hops            []Hop
+	FinalURL        string
+	StatusCode      int
+	BodyTruncated   bool
+	Depth           int
+}
+
+// textLines returns the lines printed in text output.
+func textLines(res *Result, options *input.Options) []string {
+	result := []string{}
+	domains := FindingsDomains(res.Findings)
+
+	switch {
+	case options.Registrable:
+		domains = []string{}
+
+		for _, group := range GroupFindings(res.Findings) {
+			domains = append(domains, group.Domain)
+		}
+	case options.Wildcard == input.WildcardTyped:
+		domains = []string{}
+
+		for _, f := range res.Findings {
+			domains = append(domains, f.Domain+" "+f.Type())
+		}
+
+		domains = golazy.RemoveDuplicateValues(domains)
+	}
+
+	for _, domain := range domains {
+		if domainTrimmed := strings.TrimSpace(domain); domainTrimmed != "" {
+			result = append(result, domainTrimmed)
+		}
+	}
+
+	for _, e := range res.ReportEndpoints {
+		if e.URL == "" {
+			continue
+		}
+
+		if options.Wildcard == input.WildcardTyped {
+			result = append(result, e.URL+" "+TypeReportEndpoint)
+		} else {
+			result = append(result, e.URL)
+		}
+	}
+
+	if res.Effective != nil {
+		result = append(result, effectiveLines(res.URL, "enforced", res.Effective.Enforced)...)
+		result = append(result, effectiveLines(res.URL, "report-only", res.Effective.ReportOnly)...)
+	}
+
+	for _, w := range res.Weaknesses {
+		result = append(result, res.URL+" "+w.String())
+	}
+
+	for _, b := range res.Bypasses {
+		result = append(result, res.URL+" "+b.String())
+	}
+
+	for _, d := range res.UADiffs {
+		result = append(result, res.URL+" "+d.String())
+	}
+
+	return result
+}
+
+// effectiveLines returns a line for each effective directive,
+// nothing if none of them is restricted.
+func effectiveLines(url, disposition string, directives []EffectiveDirective) []string {
+	result := []string{}
+	restricted := false
+
+	for _, d := range directives {
+		line := fmt.Sprintf("%s [%s] %s:", url, disposition, d.Name)
+
+		switch {
+		case d.Unrestricted:
+			line += " (unrestricted)"
+		case len(d.Sources) == 0:
+			line += " (none)"
+		}
+
+		for _, s := range d.Sources {
+			line += " " + s.Source.Expression
+			if s.Directive != d.Name {
+				line += " (via " + s.Directive + ")"
+			}
+		}
+
+		restricted = restricted || !d.Unrestricted
+		result = append(result, line)
+	}
+
+	if !restricted {
+		return []string{}
+	}
+
+	return result
+}
+
+// jsonData groups the findings of a target by host and lists
+// every source expression of the policies.
+func jsonData(res *Result, options *input.Options) output.JSONData {
+	if options.JSONLegacy {
+		return output.JSONData{
+			URL:             res.URL,
+			CSPResult:       FindingsDomains(res.Findings),
+			Weaknesses:      jsonWeaknesses(res.Weaknesses),
+			Bypasses:        jsonBypasses(res.Bypasses),
+			UserAgentDiffs:  jsonPolicyDiffs(res.UADiffs),
+			ReportEndpoints: jsonReportEndpoints(res.ReportEndpoints),
+			BodyTruncated:   res.BodyTruncated,
+			Depth:           res.Depth,
+		}
+	}
+
+	data := output.JSONData{
+		URL:           res.URL,
+		Hosts:         []output.JSONHost{},
+		Sources:       []output.JSONSource{},
+		BodyTruncated: res.BodyTruncated,
+		Depth:         res.Depth,
+	}
+	index := map[string]int{}
+
+	for _, f := range res.Findings {
+		i, ok := index[f.Domain]
+		if !ok {
+			i = len(data.Hosts)
+			index[f.Domain] = i
+			data.Hosts = append(data.Hosts, output.JSONHost{Host: f.Domain, Type: f.HostType.String(), IPRange: f.IPRange})
+		}
+
+		host := &data.Hosts[i]
+		host.Directives = golazy.RemoveDuplicateValues(append(host.Directives, f.Directive))
+		host.Occurrences = append(host.Occurrences, output.JSONOccurrence{
+			Hop:         f.Hop,
+			Directive:   f.Directive,
+			Origin:      f.Origin,
+			Disposition: f.Disposition,
+			Expression:  f.Source.Expression,
+			Scheme:      f.Source.Scheme,
+			Port:        f.Source.Port,
+			Path:        f.Source.Path,
+		})
+	}
+
+	for i, hop := range res.Hops {
+		data.Sources = append(data.Sources, jsonSources(hop.Policies, i, options)...)
+	}
+
+	data.Sources = append(data.Sources, jsonSources(res.Policies, len(res.Hops), options)...)
+
+	if len(res.Hops) != 0 {
+		data.Chain = jsonChain(res)
+	}
+
+	if options.Registrable {
+		for _, group := range GroupFindings(res.Findings) {
+			data.Domains = append(data.Domains, output.JSONDomain{Domain: group.Domain, Hosts: group.Hosts})
+		}
+	}
+
+	data.Weaknesses = jsonWeaknesses(res.Weaknesses)
+	data.Bypasses = jsonBypasses(res.Bypasses)
+	data.UserAgentDiffs = jsonPolicyDiffs(res.UADiffs)
+	data.ReportEndpoints = jsonReportEndpoints(res.ReportEndpoints)
+
+	if res.Effective != nil {
+		data.Effective = &output.JSONEffective{
+			Enforced:   jsonEffectiveDirectives(res.Effective.Enforced),
+			ReportOnly: jsonEffectiveDirectives(res.Effective.ReportOnly),
+		}
+	}
+
+	return data
+}
+
+func jsonWeaknesses(weaknesses []Weakness) []output.JSONWeakness {
+	result := []output.JSONWeakness{}
+
+	for _, w := range weaknesses {
+		result = append(result, output.JSONWeakness{
+			RuleID:      w.RuleID,
+			Severity:    w.Severity.String(),
+			Directive:   w.Directive,
+			Origin:      w.Origin,
+			Description: w.Description,
+		})
+	}
+
+	return result
+}
+
+// jsonSources lists the source expressions of the policies
+// delivered with the response at the given redirect chain position.
+func jsonSources(policies []Policy, hop int, options *input.Options) []output.JSONSource {
+	result := []output.JSONSource{}
+
+	for _, policy := range policies {
+		for _, d := range policy.Directives {
+			for _, s := range d.Sources {
+				if s.Type == SourceHost && len(options.Domain) != 0 && !DomainOk(s.Host, options.Domain) {
+					continue
+				}
+
+				if len(options.Origin) != 0 && !OriginOk(policy.Origin, options.Origin) {
+					continue
+				}
+
+				source := jsonSource(policy.Origin, d.Name, s)
+				source.Hop = hop
+				result = append(result, source)
+			}
+		}
+	}
+
+	return result
+}
+
+// jsonChain lists the responses of the redirect chain, the final one included.
+func jsonChain(res *Result) []output.JSONHop {
+	result := []output.JSONHop{}
+
+	for i, hop := range res.Hops {
+		result = append(result, output.JSONHop{
+			Hop:        i,
+			URL:        hop.URL,
+			StatusCode: hop.StatusCode,
+			Location:   hop.Location,
+		})
+	}
+
+	return append(result, output.JSONHop{Hop: len(res.Hops), URL: res.FinalURL, StatusCode: res.StatusCode})
+}
+
+func jsonSource(origin, directive string, s Source) output.JSONSource {
+	return output.JSONSource{
+		Directive:   directive,
+		Origin:      origin,
+		Disposition: OriginDisposition(origin),
+		Expression:  s.Expression,
+		Type:        s.Type.String(),
+		Scheme:      s.Scheme,
+		Host:        s.Host,
+		Port:        s.Port,
+		Path:        s.Path,
+		Value:       s.Value,
+		Algorithm:   s.Algorithm,
+	}
+}
+
+func jsonBypasses(bypasses []Bypass) []output.JSONBypass {
+	result := []output.JSONBypass{}
+
+	for _, b := range bypasses {
+		result = append(result, output.JSONBypass{
+			Directive:  b.Directive,
+			Origin:     b.Origin,
+			Expression: b.Source.Expression,
+			Host:       b.Source.Host,
+			Technique:  b.Technique,
+			Example:    b.Example,
+		})
+	}
+
+	return result
+}
+
+func jsonPolicyDiffs(diffs []PolicyDifference) []output.JSONPolicyDiff {
+	result := []output.JSONPolicyDiff{}
+
+	for _, d := range diffs {
+		result = append(result, output.JSONPolicyDiff{
+			Directive:  d.Directive,
+			Origin:     d.Origin,
+			Expression: d.Expression,
+			Present:    d.Present,
+			Missing:    d.Missing,
+		})
+	}
+
+	return result
+}
+
+func jsonReportEndpoints(endpoints []ReportEndpoint) []output.JSONReportEndpoint {
+	result := []output.JSONReportEndpoint{}
+
+	for _, e := range endpoints {
+		result = append(result, output.JSONReportEndpoint{
+			Directive: e.Directive,
+			Origin:    e.Origin,
+			Group:     e.Group,
+			URL:       e.URL,
+		})
+	}
+
+	return result
+}
+
+func jsonEffectiveDirectives(directives []EffectiveDirective) []output.JSONEffectiveDirective {
+	result := []output.JSONEffectiveDirective{}
+
+	for _, d := range directives {
+		directive := output.JSONEffectiveDirective{
+			Directive:    d.Name,
+			Unrestricted: d.Unrestricted,
+			Sources:      []output.JSONEffectiveSource{},
+		}
+
+		for _, s := range d.Sources {
+			directive.Sources = append(directive.Sources, output.JSONEffectiveSource{
+				Expression: s.Source.Expression,
+				Type:       s.Source.Type.String(),
+				Directive:  s.Directive,
+				Origin:     s.Origin,
+			})
+		}
+
+		result = append(result, directive)
+	}
+
+	return result
+}
@@ -0,0 +1,63 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"regexp"
+
+	"github.com/edoardottt/golazy"
+)
+
+const (
+	OriginTLSSAN = "tls-san"
+	// FieldSAN and FieldCN are the certificate fields
+	// used as directive of the TLS findings.
+	FieldSAN = "subject-alt-name"
+	FieldCN  = "common-name"
+)
+
+// peerCertificate returns the leaf certificate of the
+// connection, nil if the connection is not over TLS.
+func peerCertificate(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return state.PeerCertificates[0]
+}
+
+// TLSFindings returns the findings matching the regex found in the
+// Subject Alternative Names (DNS names and IP addresses) and in the
+// Common Name of the certificates.
+func TLSFindings(certs []*x509.Certificate, r *regexp.Regexp) []Finding {
+	result := []Finding{}
+
+	for _, cert := range certs {
+		for _, name := range cert.DNSNames {
+			result = append(result, certificateFindings(name, FieldSAN, r)...)
+		}
+
+		for _, ip := range cert.IPAddresses {
+			result = append(result, certificateFindings(ip.String(), FieldSAN, r)...)
+		}
+
+		if cert.Subject.CommonName != "" {
+			result = append(result, certificateFindings(cert.Subject.CommonName, FieldCN, r)...)
+		}
+	}
+
+	return golazy.RemoveDuplicateValues(result)
+}
+
+// certificateFindings returns the findings of a single certificate name.
+func certificateFindings(name, field string, r *regexp.Regexp) []Finding {
+	s := Source{Expression: name, Type: SourceHost, Host: name}
+
+	return sourceFindings(s, field, OriginTLSSAN, r)
+}
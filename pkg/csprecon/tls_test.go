@@ -0,0 +1,90 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSFindings(t *testing.T) {
+	certs := []*x509.Certificate{
+		{
+			Subject:     pkix.Name{CommonName: "www.example.com"},
+			DNSNames:    []string{"www.example.com", "*.cdn.example.com", "sibling.example.org"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		},
+		{
+			Subject: pkix.Name{CommonName: "Example Internal CA"},
+		},
+	}
+	findings := csprecon.TLSFindings(certs, csprecon.CompileRegex(csprecon.DomainRegex))
+
+	got := []string{}
+	for _, f := range findings {
+		require.Equal(t, csprecon.OriginTLSSAN, f.Origin)
+		require.Empty(t, f.Disposition)
+
+		got = append(got, f.Domain+" "+f.Type()+" "+f.Directive)
+	}
+
+	require.Equal(t, []string{
+		"www.example.com concrete subject-alt-name",
+		"*.cdn.example.com wildcard subject-alt-name",
+		"sibling.example.org concrete subject-alt-name",
+		"10.0.0.1 ipv4-private subject-alt-name",
+		"www.example.com concrete common-name",
+	}, got)
+
+	require.Equal(t, []string{"www.example.com", "*.cdn.example.com"},
+		csprecon.FindingsDomains(csprecon.FilterFindings(findings, []string{"example.com"})))
+	require.Len(t, csprecon.FilterFindingsByOrigin(findings, []string{"tls-san"}), len(findings))
+	require.Empty(t, csprecon.FilterFindingsByOrigin(findings, []string{"header", "enforce"}))
+}
+
+func TestCheckCSPCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, "/", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	extractors, err := csprecon.NewRegistry().Select([]string{csprecon.ExtractorTLSSAN})
+	require.NoError(t, err)
+
+	options := csprecon.RequestOptions{MaxRedirects: 1, Extractors: extractors}
+
+	got, err := csprecon.CheckCSP(server.URL+"/login", client, options)
+	require.NoError(t, err)
+	require.Len(t, got.Hops, 1)
+
+	hops := map[int][]string{}
+	for _, f := range got.Findings {
+		hops[f.Hop] = append(hops[f.Hop], f.Domain)
+	}
+
+	want := []string{"example.com", "*.example.com", "127.0.0.1", "::1"}
+	require.Equal(t, map[int][]string{0: want, 1: want}, hops)
+
+	got, err = csprecon.CheckCSP("http://"+server.Listener.Addr().String(), http.DefaultClient, options)
+	require.NoError(t, err)
+	require.Empty(t, got.Findings)
+}
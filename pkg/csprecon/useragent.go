@@ -0,0 +1,175 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+
+	"github.com/edoardottt/golazy"
+)
+
+const (
+	UserAgentDesktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 " +
+		"(KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	UserAgentMobile = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 " +
+		"(KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+	UserAgentBot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	RuleUADiff   = "ua-diff"
+	// NonceExpression replaces the nonces when comparing the
+	// policies, as a fresh one is delivered with every response.
+	NonceExpression = "'nonce-*'"
+)
+
+// Agent is a User-Agent with a short name used in the output.
+type Agent struct {
+	Name      string
+	UserAgent string
+}
+
+// DefaultCompareAgents returns the User-Agents compared by default:
+// a desktop browser, a mobile browser and a crawler.
+func DefaultCompareAgents() []Agent {
+	return []Agent{
+		{Name: "desktop", UserAgent: UserAgentDesktop},
+		{Name: "mobile", UserAgent: UserAgentMobile},
+		{Name: "bot", UserAgent: UserAgentBot},
+	}
+}
+
+// ListAgents returns the agents of a User-Agent list,
+// named after their position (ua1, ua2, ...).
+func ListAgents(userAgents []string) []Agent {
+	result := []Agent{}
+
+	for i, ua := range userAgents {
+		result = append(result, Agent{Name: fmt.Sprintf("ua%d", i+1), UserAgent: ua})
+	}
+
+	return result
+}
+
+// LoadUserAgents reads a list of User-Agents from a file,
+// one per line. Empty lines and comments (#) are skipped.
+func LoadUserAgents(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	result := []string{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			result = append(result, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return golazy.RemoveDuplicateValues(result), nil
+}
+
+// RandomUserAgent returns a random User-Agent of the list,
+// a randomly generated one if the list is empty.
+func RandomUserAgent(userAgents []string) string {
+	if len(userAgents) == 0 {
+		return golazy.GenerateRandomUserAgent()
+	}
+
+	return userAgents[rand.IntN(len(userAgents))]
+}
+
+// PolicyDifference is a source expression (or a directive without
+// sources, when Expression is empty) delivered only to some agents.
+type PolicyDifference struct {
+	Origin     string
+	Directive  string
+	Expression string
+	Present    []string
+	Missing    []string
+}
+
+// String returns the difference as a single line.
+func (d PolicyDifference) String() string {
+	what := d.Expression
+	if what == "" {
+		what = "directive"
+	}
+
+	return fmt.Sprintf("[%s] %s (%s): %s only for %s, missing for %s",
+		RuleUADiff, d.Directive, d.Origin, what, strings.Join(d.Present, ", "), strings.Join(d.Missing, ", "))
+}
+
+// ComparePolicies returns the differences between the policies delivered
+// to each agent: policies[i] are the policies received by agents[i].
+// Nonces are compared as NonceExpression.
+func ComparePolicies(agents []string, policies [][]Policy) []PolicyDifference {
+	type key struct {
+		origin, directive, expression string
+	}
+
+	keys := []key{}
+	present := map[key]map[int]struct{}{}
+	add := func(k key, agent int) {
+		if _, ok := present[k]; !ok {
+			keys = append(keys, k)
+			present[k] = map[int]struct{}{}
+		}
+
+		present[k][agent] = struct{}{}
+	}
+
+	for i := range agents {
+		for _, policy := range policies[i] {
+			for _, d := range policy.Directives {
+				if len(d.Sources) == 0 {
+					add(key{policy.Origin, d.Name, ""}, i)
+				}
+
+				for _, s := range d.Sources {
+					expression := s.Expression
+					if s.Type == SourceNonce {
+						expression = NonceExpression
+					}
+
+					add(key{policy.Origin, d.Name, expression}, i)
+				}
+			}
+		}
+	}
+
+	result := []PolicyDifference{}
+
+	for _, k := range keys {
+		if len(present[k]) == len(agents) {
+			continue
+		}
+
+		diff := PolicyDifference{Origin: k.origin, Directive: k.directive, Expression: k.expression}
+
+		for i, agent := range agents {
+			if _, ok := present[k][i]; ok {
+				diff.Present = append(diff.Present, agent)
+			} else {
+				diff.Missing = append(diff.Missing, agent)
+			}
+		}
+
+		result = append(result, diff)
+	}
+
+	return result
+}
@@ -0,0 +1,151 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package csprecon_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/edoardottt/csprecon/pkg/csprecon"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComparePolicies(t *testing.T) {
+	policies := func(origin, csp string) []csprecon.Policy {
+		result := csprecon.ParsePolicyList(csp)
+		for i := range result {
+			result[i].Origin = origin
+		}
+
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		agents   []string
+		policies [][]csprecon.Policy
+		want     []csprecon.PolicyDifference
+	}{
+		{
+			name:   "same policies",
+			agents: []string{"desktop", "mobile"},
+			policies: [][]csprecon.Policy{
+				policies(csprecon.HeaderCSP, "script-src 'self' a.example.com"),
+				policies(csprecon.HeaderCSP, "script-src 'self' a.example.com"),
+			},
+			want: []csprecon.PolicyDifference{},
+		},
+		{
+			name:   "different sources",
+			agents: []string{"desktop", "mobile", "bot"},
+			policies: [][]csprecon.Policy{
+				policies(csprecon.HeaderCSP, "script-src 'self' a.example.com; upgrade-insecure-requests"),
+				policies(csprecon.HeaderCSP, "script-src 'self' m.example.com; upgrade-insecure-requests"),
+				{},
+			},
+			want: []csprecon.PolicyDifference{
+				{
+					Origin: csprecon.HeaderCSP, Directive: "script-src", Expression: "'self'",
+					Present: []string{"desktop", "mobile"}, Missing: []string{"bot"},
+				},
+				{
+					Origin: csprecon.HeaderCSP, Directive: "script-src", Expression: "a.example.com",
+					Present: []string{"desktop"}, Missing: []string{"mobile", "bot"},
+				},
+				{
+					Origin: csprecon.HeaderCSP, Directive: "upgrade-insecure-requests",
+					Present: []string{"desktop", "mobile"}, Missing: []string{"bot"},
+				},
+				{
+					Origin: csprecon.HeaderCSP, Directive: "script-src", Expression: "m.example.com",
+					Present: []string{"mobile"}, Missing: []string{"desktop", "bot"},
+				},
+			},
+		},
+		{
+			name:   "different nonces",
+			agents: []string{"desktop", "mobile"},
+			policies: [][]csprecon.Policy{
+				policies(csprecon.HeaderCSP, "script-src 'nonce-YWJj' 'strict-dynamic'"),
+				policies(csprecon.HeaderCSP, "script-src 'nonce-ZGVm' 'strict-dynamic'"),
+			},
+			want: []csprecon.PolicyDifference{},
+		},
+		{
+			name:   "nonce for some agents",
+			agents: []string{"desktop", "bot"},
+			policies: [][]csprecon.Policy{
+				policies(csprecon.HeaderCSP, "script-src 'nonce-YWJj'"),
+				policies(csprecon.HeaderCSP, "script-src 'self'"),
+			},
+			want: []csprecon.PolicyDifference{
+				{
+					Origin: csprecon.HeaderCSP, Directive: "script-src", Expression: csprecon.NonceExpression,
+					Present: []string{"desktop"}, Missing: []string{"bot"},
+				},
+				{
+					Origin: csprecon.HeaderCSP, Directive: "script-src", Expression: "'self'",
+					Present: []string{"bot"}, Missing: []string{"desktop"},
+				},
+			},
+		},
+		{
+			name:   "different origins",
+			agents: []string{"desktop", "bot"},
+			policies: [][]csprecon.Policy{
+				policies(csprecon.HeaderCSP, "img-src a.example.com"),
+				policies(csprecon.HeaderCSPReportOnly, "img-src a.example.com"),
+			},
+			want: []csprecon.PolicyDifference{
+				{
+					Origin: csprecon.HeaderCSP, Directive: "img-src", Expression: "a.example.com",
+					Present: []string{"desktop"}, Missing: []string{"bot"},
+				},
+				{
+					Origin: csprecon.HeaderCSPReportOnly, Directive: "img-src", Expression: "a.example.com",
+					Present: []string{"bot"}, Missing: []string{"desktop"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, csprecon.ComparePolicies(tt.agents, tt.policies))
+		})
+	}
+
+	diff := csprecon.PolicyDifference{
+		Origin: csprecon.HeaderCSP, Directive: "script-src", Expression: "a.example.com",
+		Present: []string{"desktop"}, Missing: []string{"mobile", "bot"},
+	}
+	require.Equal(t, "[ua-diff] script-src (Content-Security-Policy): a.example.com only for desktop, missing for mobile, bot",
+		diff.String())
+}
+
+func TestListAgents(t *testing.T) {
+	require.Equal(t, []csprecon.Agent{
+		{Name: "ua1", UserAgent: "Mozilla/5.0 (X11; Linux x86_64)"},
+		{Name: "ua2", UserAgent: "curl/8.0"},
+	}, csprecon.ListAgents([]string{"Mozilla/5.0 (X11; Linux x86_64)", "curl/8.0"}))
+}
+
+func TestLoadUserAgents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user-agents.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# browsers\nua-1\n\n  ua-2  \nua-1\n"), csprecon.DefaultFilePermission))
+
+	got, err := csprecon.LoadUserAgents(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ua-1", "ua-2"}, got)
+
+	require.Contains(t, got, csprecon.RandomUserAgent(got))
+	require.NotEmpty(t, csprecon.RandomUserAgent(nil))
+
+	_, err = csprecon.LoadUserAgents(filepath.Join(t.TempDir(), "missing.txt"))
+	require.Error(t, err)
+}
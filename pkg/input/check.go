@@ -10,24 +10,77 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
 
 	fileutil "github.com/projectdiscovery/utils/file"
 )
 
 var (
-	ErrMutexFlags    = errors.New("incompatible flags specified")
-	ErrNoInput       = errors.New("no input specified")
-	ErrNegativeValue = errors.New("must be positive")
-	ErrCidrBadFormat = errors.New("malformed input CIDR")
-	ErrMalformedURL  = errors.New("malformed input URL")
+	ErrMutexFlags          = errors.New("incompatible flags specified")
+	ErrNoInput             = errors.New("no input specified")
+	ErrNegativeValue       = errors.New("must be positive")
+	ErrCidrBadFormat       = errors.New("malformed input CIDR")
+	ErrMalformedURL        = errors.New("malformed input URL")
+	ErrFileNotFound        = errors.New("file not found")
+	ErrNoRegistrableDomain = errors.New("no registrable domain")
+	ErrInvalidValue        = errors.New("invalid value")
 )
 
-func (options *Options) validateOptions() error {
+const (
+	SchemeHTTP       = "http"
+	SchemeHTTPS      = "https"
+	DefaultHTTPPort  = "80"
+	DefaultHTTPSPort = "443"
+	MaxPort          = 65535
+)
+
+// Probe is a scheme and port combination tried for bare hosts and IPs.
+type Probe struct {
+	Scheme string
+	Port   string
+}
+
+// ParseProbe parses a probe: a port (e.g. 8443), a scheme (e.g. https)
+// or both (e.g. http:8080). When missing, the scheme is https for ports
+// 443 and 8443 and http otherwise, the port is the default of the scheme.
+func ParseProbe(value string) (Probe, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	scheme, port, found := strings.Cut(value, ":")
+
+	if !found {
+		switch value {
+		case SchemeHTTP:
+			return Probe{Scheme: SchemeHTTP, Port: DefaultHTTPPort}, nil
+		case SchemeHTTPS:
+			return Probe{Scheme: SchemeHTTPS, Port: DefaultHTTPSPort}, nil
+		}
+
+		scheme, port = SchemeHTTP, value
+		if port == DefaultHTTPSPort || port == "8443" {
+			scheme = SchemeHTTPS
+		}
+	}
+
+	if scheme != SchemeHTTP && scheme != SchemeHTTPS {
+		return Probe{}, fmt.Errorf("probe %s: %w", value, ErrInvalidValue)
+	}
+
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > MaxPort {
+		return Probe{}, fmt.Errorf("probe %s: %w", value, ErrInvalidValue)
+	}
+
+	return Probe{Scheme: scheme, Port: port}, nil
+}
+
+// Validate checks the options, it must be called by the library users
+// building the options themselves (csprecon.New does it).
+func (options *Options) Validate() error {
 	if options.Silent && options.Verbose {
 		return fmt.Errorf("%w: %s and %s", ErrMutexFlags, "silent", "verbose")
 	}
 
-	if options.Input == "" && options.FileInput == "" && !fileutil.HasStdin() {
+	if options.Input == "" && options.FileInput == "" && options.Stdin == nil {
 		return fmt.Errorf("%w", ErrNoInput)
 	}
 
@@ -39,14 +92,110 @@ func (options *Options) validateOptions() error {
 		return fmt.Errorf("rate limit: %w", ErrNegativeValue)
 	}
 
+	if options.BodyLimit < 0 {
+		return fmt.Errorf("body limit: %w", ErrNegativeValue)
+	}
+
+	if options.MaxIdleConns < 0 || options.MaxIdleConnsPerHost < 0 || options.MaxConnsPerHost < 0 {
+		return fmt.Errorf("connection pool size: %w", ErrNegativeValue)
+	}
+
 	if options.Proxy != "" && !checkProxy(options.Proxy) {
 		_, err := url.Parse(options.Proxy)
 		return fmt.Errorf("proxy URL: %w", err)
 	}
 
+	switch options.Wildcard {
+	case WildcardAll, WildcardConcrete, WildcardBase, WildcardTyped:
+	default:
+		return fmt.Errorf("wildcard %s: %w", options.Wildcard, ErrInvalidValue)
+	}
+
+	switch options.Redirects {
+	case RedirectNone, RedirectSameHost, RedirectAll:
+	default:
+		return fmt.Errorf("follow redirects %s: %w", options.Redirects, ErrInvalidValue)
+	}
+
+	switch options.Graph {
+	case "", GraphDOT, GraphGraphML, GraphMermaid, GraphJSON:
+	default:
+		return fmt.Errorf("graph %s: %w", options.Graph, ErrInvalidValue)
+	}
+
+	if options.Graph != "" && options.JSON {
+		return fmt.Errorf("%w: %s and %s", ErrMutexFlags, "graph", "json")
+	}
+
+	if options.RecursionDepth < 0 {
+		return fmt.Errorf("recursion depth: %w", ErrNegativeValue)
+	}
+
+	if options.MaxRedirects < 0 {
+		return fmt.Errorf("max redirects: %w", ErrNegativeValue)
+	}
+
+	for _, probe := range options.Probes {
+		if _, err := ParseProbe(probe); err != nil {
+			return err
+		}
+	}
+
+	for i, h := range options.Headers {
+		// Don't print the header, it may contain secrets.
+		if _, _, ok := ParseHeader(h); !ok {
+			return fmt.Errorf("header #%d: %w", i+1, ErrInvalidValue)
+		}
+	}
+
+	if options.BasicAuth != "" && options.BearerToken != "" {
+		return fmt.Errorf("%w: %s and %s", ErrMutexFlags, "basic-auth", "bearer-token")
+	}
+
+	if options.BasicAuth != "" && !strings.Contains(options.BasicAuth, ":") {
+		return fmt.Errorf("basic auth credentials (user:password): %w", ErrInvalidValue)
+	}
+
+	if options.UserAgent != "" && (options.UserAgentList != "" || options.RotateUserAgent || options.CompareUserAgents) {
+		return fmt.Errorf("%w: %s and %s", ErrMutexFlags, "user-agent", "user-agent-list/rotate/compare")
+	}
+
+	if options.RotateUserAgent && options.CompareUserAgents {
+		return fmt.Errorf("%w: %s and %s", ErrMutexFlags, "user-agent-rotate", "user-agent-compare")
+	}
+
+	if options.FileInput != "" && !fileutil.FileExists(options.FileInput) {
+		return fmt.Errorf("list %s: %w", options.FileInput, ErrFileNotFound)
+	}
+
+	if options.UserAgentList != "" && !fileutil.FileExists(options.UserAgentList) {
+		return fmt.Errorf("user agent list %s: %w", options.UserAgentList, ErrFileNotFound)
+	}
+
+	if options.CookieFile != "" && !fileutil.FileExists(options.CookieFile) {
+		return fmt.Errorf("cookie file %s: %w", options.CookieFile, ErrFileNotFound)
+	}
+
+	if options.BypassDB != "" && !fileutil.FileExists(options.BypassDB) {
+		return fmt.Errorf("bypass dataset %s: %w", options.BypassDB, ErrFileNotFound)
+	}
+
 	return nil
 }
 
+// ParseHeader returns the name and the value of a custom header
+// (Name: value). ok is false if the name is missing or contains spaces.
+func ParseHeader(h string) (name, value string, ok bool) {
+	name, value, ok = strings.Cut(h, ":")
+	name = strings.TrimSpace(name)
+
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", false
+	}
+
+	return name, strings.TrimSpace(value), true
+}
+
 func checkProxy(proxy string) bool {
 	if len(proxy) == 0 {
 		return false
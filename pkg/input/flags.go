@@ -15,6 +15,7 @@ import (
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/levels"
+	fileutil "github.com/projectdiscovery/utils/file"
 )
 
 const (
@@ -22,22 +23,93 @@ const (
 	DefaultConcurrency = 50
 	DefaultRateLimit   = 0
 	DefaultNoFlags     = 2
+	DefaultBodyLimit   = 500
+	DefaultRedirects   = 10
+	DefaultIdleConns   = 100
+	DefaultIdlePerHost = 10
+)
+
+const (
+	WildcardAll      = "all"
+	WildcardConcrete = "concrete"
+	WildcardBase     = "base"
+	WildcardTyped    = "typed"
+)
+
+const (
+	GraphDOT     = "dot"
+	GraphGraphML = "graphml"
+	GraphMermaid = "mermaid"
+	GraphJSON    = "json"
+)
+
+const (
+	RedirectNone     = "none"
+	RedirectSameHost = "same-host"
+	RedirectAll      = "all"
 )
 
 type Options struct {
-	Input       string
-	FileInput   string
-	FileOutput  string
-	Domain      goflags.StringSlice
-	Verbose     bool
-	Output      io.Writer
-	Silent      bool
-	JSON        bool
-	Concurrency int
-	Timeout     int
-	Cidr        bool
-	RateLimit   int
-	Proxy       string
+	Input               string
+	FileInput           string
+	FileOutput          string
+	Domain              goflags.StringSlice
+	Origin              goflags.StringSlice
+	Verbose             bool
+	Output              io.Writer
+	Silent              bool
+	JSON                bool
+	JSONLegacy          bool
+	Registrable         bool
+	Wildcard            string
+	Concurrency         int
+	Timeout             int
+	Cidr                bool
+	RateLimit           int
+	Proxy               string
+	Analyze             bool
+	BypassDB            string
+	ReportEndpoints     bool
+	Effective           bool
+	BodyLimit           int
+	ForceBody           bool
+	Redirects           string
+	MaxRedirects        int
+	Probes              goflags.StringSlice
+	ProbeFirst          bool
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	Headers             goflags.StringSlice
+	CookieFile          string
+	BasicAuth           string
+	BearerToken         string
+	UserAgent           string
+	UserAgentList       string
+	RotateUserAgent     bool
+	CompareUserAgents   bool
+	TLSSAN              bool
+	HeaderExtract       goflags.StringSlice
+	Extractors          goflags.StringSlice
+	RecursionDepth      int
+	Graph               string
+	Stdin               io.Reader
+}
+
+// DefaultOptions returns the options with the default values
+// of the flags, to be completed with the input.
+func DefaultOptions() *Options {
+	return &Options{
+		Wildcard:            WildcardAll,
+		Concurrency:         DefaultConcurrency,
+		Timeout:             DefaultTimeout,
+		RateLimit:           DefaultRateLimit,
+		BodyLimit:           DefaultBodyLimit,
+		Redirects:           RedirectAll,
+		MaxRedirects:        DefaultRedirects,
+		MaxIdleConns:        DefaultIdleConns,
+		MaxIdleConnsPerHost: DefaultIdlePerHost,
+	}
 }
 
 // configureOutput configures the output on the screen.
@@ -61,14 +133,40 @@ func ParseOptions() *Options {
 		flagSet.StringVarP(&options.Input, "url", "u", "", `Input domain`),
 		flagSet.StringVarP(&options.FileInput, "list", "l", "", `File containing input domains`),
 		flagSet.BoolVar(&options.Cidr, "cidr", false, `Interpret input as CIDR`),
+		flagSet.IntVarP(&options.RecursionDepth, "recursion-depth", "rec", 0, `Scan the concrete in-scope hosts found up to this depth (0 = disabled)`),
+		flagSet.StringSliceVarP(&options.Probes, "probe", "p", nil, `Probe bare hosts and IPs on these ports or scheme:port pairs (e.g. 80,443,http:8080,https:8443)`, goflags.CommaSeparatedStringSliceOptions),
+		flagSet.BoolVarP(&options.ProbeFirst, "probe-first", "pf", false, `Stop probing a host at the first responding endpoint`),
 	)
 
 	flagSet.CreateGroup("configs", "Configurations",
 		flagSet.StringSliceVarP(&options.Domain, "domain", "d", nil, `Filter results belonging to these domains (comma separated)`, goflags.CommaSeparatedStringSliceOptions),
+		flagSet.StringSliceVarP(&options.Origin, "origin", "or", nil, `Filter results by origin: header name, meta, tls-san, header, legacy, enforce or report-only (comma separated)`, goflags.CommaSeparatedStringSliceOptions),
 		flagSet.IntVarP(&options.Concurrency, "concurrency", "c", DefaultConcurrency, `Concurrency level`),
 		flagSet.IntVarP(&options.Timeout, "timeout", "t", DefaultTimeout, `Connection timeout in seconds`),
 		flagSet.IntVarP(&options.RateLimit, "rate-limit", "rl", DefaultRateLimit, `Set a rate limit (per second)`),
 		flagSet.StringVarP(&options.Proxy, "proxy", "px", "", `Set a proxy server (URL)`),
+		flagSet.StringSliceVarP(&options.Headers, "header", "H", nil, `Custom header sent with every request ("Name: value", repeatable)`, goflags.StringSliceOptions),
+		flagSet.StringVarP(&options.CookieFile, "cookie-file", "cf", "", `File containing the cookies to send (Netscape format)`),
+		flagSet.StringVarP(&options.BasicAuth, "basic-auth", "ba", "", `Basic auth credentials (user:password)`),
+		flagSet.StringVarP(&options.BearerToken, "bearer-token", "bt", "", `Bearer token sent in the Authorization header`),
+		flagSet.StringVarP(&options.UserAgent, "user-agent", "ua", "", `Fixed User-Agent (default random)`),
+		flagSet.StringVarP(&options.UserAgentList, "user-agent-list", "ual", "", `File containing the User-Agents to use (one per line)`),
+		flagSet.BoolVarP(&options.RotateUserAgent, "user-agent-rotate", "uar", false, `Use a random User-Agent for every target`),
+		flagSet.BoolVarP(&options.CompareUserAgents, "user-agent-compare", "uac", false, `Compare the CSP served to desktop, mobile and bot User-Agents (or the ones of the list)`),
+		flagSet.IntVarP(&options.MaxIdleConns, "max-idle-conns", "mic", DefaultIdleConns, `Max idle connections kept in the pool`),
+		flagSet.IntVarP(&options.MaxIdleConnsPerHost, "max-idle-conns-per-host", "mich", DefaultIdlePerHost, `Max idle connections kept in the pool per host`),
+		flagSet.IntVarP(&options.MaxConnsPerHost, "max-conns-per-host", "mch", 0, `Max connections per host (0 = unlimited)`),
+		flagSet.IntVarP(&options.BodyLimit, "body-limit", "bl", DefaultBodyLimit, `Max HTML body size to read in KB (0 = headers only)`),
+		flagSet.BoolVarP(&options.ForceBody, "force-body", "fb", false, `Parse the body even if the Content-Type is not HTML`),
+		flagSet.StringVarP(&options.Redirects, "follow-redirects", "fr", RedirectAll, `Redirects handling (none, same-host, all)`),
+		flagSet.IntVarP(&options.MaxRedirects, "max-redirects", "mr", DefaultRedirects, `Max number of redirects to follow`),
+		flagSet.StringSliceVarP(&options.HeaderExtract, "header-extract", "hx", nil, `Extract hosts from other headers: all or header names (Access-Control-Allow-Origin, Link, Alt-Svc, Permissions-Policy, Set-Cookie, Timing-Allow-Origin)`, goflags.CommaSeparatedStringSliceOptions),
+		flagSet.BoolVarP(&options.TLSSAN, "tls-san", "ts", false, `Report the hosts found in the TLS certificates (SAN, CN)`),
+		flagSet.StringSliceVarP(&options.Extractors, "extractor", "x", nil, `Enable extractors by name: all, tls-san, header names or registered ones (comma separated)`, goflags.CommaSeparatedStringSliceOptions),
+		flagSet.BoolVarP(&options.Effective, "effective", "ef", false, `Report the effective allowlist of each directive combining all the policies`),
+		flagSet.BoolVarP(&options.Analyze, "analyze", "a", false, `Evaluate the policies and report their weaknesses`),
+		flagSet.BoolVarP(&options.ReportEndpoints, "report-endpoints", "re", false, `Report the CSP reporting endpoints (report-uri, report-to)`),
+		flagSet.StringVarP(&options.BypassDB, "bypass-db", "bdb", "", `File containing a custom dataset of bypassable hosts (JSON)`),
 	)
 
 	// Output
@@ -77,6 +175,10 @@ func ParseOptions() *Options {
 		flagSet.BoolVarP(&options.Verbose, "verbose", "v", false, `Verbose output`),
 		flagSet.BoolVarP(&options.Silent, "silent", "s", false, `Silent output. Print only results`),
 		flagSet.BoolVarP(&options.JSON, "json", "j", false, `JSON output`),
+		flagSet.StringVarP(&options.Wildcard, "wildcard", "w", WildcardAll, `Wildcard hosts handling (all, concrete, base, typed)`),
+		flagSet.BoolVarP(&options.Registrable, "registrable-domain", "rd", false, `Group results by registrable domain (eTLD+1)`),
+		flagSet.BoolVarP(&options.JSONLegacy, "json-legacy", "jl", false, `JSON output with the legacy flat result list (implies -j)`),
+		flagSet.StringVarP(&options.Graph, "graph", "gr", "", `Output the graph of the targets and the hosts they trust (dot, graphml, mermaid, json)`),
 	)
 
 	if help() || noArgs() {
@@ -88,10 +190,18 @@ func ParseOptions() *Options {
 		gologger.Fatal().Msgf("%s\n", err)
 	}
 
+	if options.JSONLegacy {
+		options.JSON = true
+	}
+
+	if fileutil.HasStdin() {
+		options.Stdin = os.Stdin
+	}
+
 	// Read the inputs and configure the logging.
 	options.configureOutput()
 
-	if err := options.validateOptions(); err != nil {
+	if err := options.Validate(); err != nil {
 		output.ShowBanner()
 		gologger.Fatal().Msgf("%s\n", err)
 	}
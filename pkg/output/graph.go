@@ -0,0 +1,212 @@
+/*
+csprecon - Discover new target domains using Content Security Policy
+
+This repository is under MIT License https://github.com/edoardottt/csprecon/blob/main/LICENSE
+*/
+
+package output
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	NodeTarget = "target"
+	NodeHost   = "host"
+)
+
+// Graph is the directed graph of the trust relationships: an edge
+// goes from a scanned target to a host its policies (or any other
+// source) allow. Targets found recursively are both the end of an
+// edge and the start of others.
+type Graph struct {
+	Nodes []GraphNode `json:"Nodes"`
+	Edges []GraphEdge `json:"Edges"`
+}
+
+// GraphNode is a host. Targets have the URL scanned
+// and the recursion depth they were found at.
+type GraphNode struct {
+	ID       string `json:"ID"`
+	Type     string `json:"Type"`
+	HostType string `json:"HostType,omitempty"`
+	URL      string `json:"URL,omitempty"`
+	Depth    int    `json:"Depth,omitempty"`
+}
+
+// GraphEdge is a host allowed by a target for a directive
+// (or header field), with the source expression allowing it
+// and the origins it was found in.
+type GraphEdge struct {
+	From      string   `json:"From"`
+	To        string   `json:"To"`
+	Directive string   `json:"Directive"`
+	Source    string   `json:"Source"`
+	Origins   []string `json:"Origins"`
+}
+
+// Label returns the label of the edge (e.g. script-src: https://cdn.example.com).
+func (e GraphEdge) Label() string {
+	if e.Source == "" || e.Source == e.To {
+		return e.Directive
+	}
+
+	return e.Directive + ": " + e.Source
+}
+
+// FormatGraphJSON returns the graph as a JSON object of nodes and edges.
+func FormatGraphJSON(g *Graph) ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// FormatGraphDOT returns the graph in the Graphviz DOT language.
+// Targets are drawn as boxes, the other hosts as ellipses.
+func FormatGraphDOT(g *Graph) []byte {
+	var b strings.Builder
+
+	b.WriteString("digraph csprecon {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	for _, n := range g.Nodes {
+		shape := "ellipse"
+		if n.Type == NodeTarget {
+			shape = "box"
+		}
+
+		fmt.Fprintf(&b, "  %s [shape=%s];\n", strconv.Quote(n.ID), shape)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(e.Label()))
+	}
+
+	b.WriteString("}")
+
+	return []byte(b.String())
+}
+
+// FormatGraphMermaid returns the graph as a Mermaid flowchart.
+// Nodes are named by their position, labelled with the host.
+func FormatGraphMermaid(g *Graph) []byte {
+	var b strings.Builder
+
+	b.WriteString("flowchart LR\n")
+
+	ids := map[string]string{}
+
+	for i, n := range g.Nodes {
+		ids[n.ID] = "n" + strconv.Itoa(i)
+
+		open, closing := "([", "])"
+		if n.Type == NodeTarget {
+			open, closing = "[", "]"
+		}
+
+		fmt.Fprintf(&b, "  %s%s\"%s\"%s\n", ids[n.ID], open, mermaidEscape(n.ID), closing)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[e.From], mermaidEscape(e.Label()), ids[e.To])
+	}
+
+	return []byte(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// mermaidEscape escapes the characters breaking a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "|", "#124;").Replace(s)
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// FormatGraphGraphML returns the graph as a GraphML document.
+func FormatGraphGraphML(g *Graph) ([]byte, error) {
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "type", For: "node", Name: "type", Type: "string"},
+			{ID: "hosttype", For: "node", Name: "hostType", Type: "string"},
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "directive", For: "edge", Name: "directive", Type: "string"},
+			{ID: "source", For: "edge", Name: "source", Type: "string"},
+			{ID: "origins", For: "edge", Name: "origins", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "csprecon", EdgeDefault: "directed"},
+	}
+
+	for _, n := range g.Nodes {
+		node := graphMLNode{ID: n.ID, Data: []graphMLData{{Key: "type", Value: n.Type}}}
+
+		if n.HostType != "" {
+			node.Data = append(node.Data, graphMLData{Key: "hosttype", Value: n.HostType})
+		}
+
+		if n.Type == NodeTarget {
+			node.Data = append(node.Data,
+				graphMLData{Key: "url", Value: n.URL},
+				graphMLData{Key: "depth", Value: strconv.Itoa(n.Depth)},
+			)
+		}
+
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+
+	for _, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.From,
+			Target: e.To,
+			Data: []graphMLData{
+				{Key: "directive", Value: e.Directive},
+				{Key: "source", Value: e.Source},
+				{Key: "origins", Value: strings.Join(e.Origins, ",")},
+			},
+		})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
@@ -19,19 +19,156 @@ type JSONResult struct {
 }
 
 // JSONData.
+// CSPResult is the legacy flat list of results, Hosts
+// holds the results attributed to directives and origins,
+// Domains the hosts grouped by registrable domain, Sources
+// every classified source expression, Weaknesses the issues
+// found evaluating the policies, Bypasses the allowlisted
+// sources enabling known bypass techniques, ReportEndpoints
+// the CSP reporting endpoints, Effective the allowlists
+// resulting from combining all the policies, UserAgentDiffs
+// the differences between the policies served to each User-Agent
+// and Depth the recursion depth the target was found at.
 type JSONData struct {
-	URL       string   `json:"URL,omitempty"`
-	CSPResult []string `json:"CSPResult,omitempty"`
+	URL             string               `json:"URL,omitempty"`
+	CSPResult       []string             `json:"CSPResult,omitempty"`
+	Hosts           []JSONHost           `json:"Hosts,omitempty"`
+	Domains         []JSONDomain         `json:"Domains,omitempty"`
+	Sources         []JSONSource         `json:"Sources,omitempty"`
+	Weaknesses      []JSONWeakness       `json:"Weaknesses,omitempty"`
+	Bypasses        []JSONBypass         `json:"Bypasses,omitempty"`
+	ReportEndpoints []JSONReportEndpoint `json:"ReportEndpoints,omitempty"`
+	Effective       *JSONEffective       `json:"Effective,omitempty"`
+	UserAgentDiffs  []JSONPolicyDiff     `json:"UserAgentDiffs,omitempty"`
+	Chain           []JSONHop            `json:"Chain,omitempty"`
+	BodyTruncated   bool                 `json:"BodyTruncated,omitempty"`
+	Depth           int                  `json:"Depth,omitempty"`
+}
+
+// JSONHop is a response of the redirect chain. Hosts and sources
+// refer to it with its position (Hop).
+type JSONHop struct {
+	Hop        int    `json:"Hop"`
+	URL        string `json:"URL"`
+	StatusCode int    `json:"StatusCode"`
+	Location   string `json:"Location,omitempty"`
+}
+
+// JSONHost is a discovered host with every place it was found in.
+type JSONHost struct {
+	Host        string           `json:"Host"`
+	Type        string           `json:"Type"`
+	IPRange     string           `json:"IPRange,omitempty"`
+	Directives  []string         `json:"Directives"`
+	Occurrences []JSONOccurrence `json:"Occurrences"`
+}
+
+// JSONDomain is a registrable domain with the hosts belonging to it.
+type JSONDomain struct {
+	Domain string   `json:"Domain"`
+	Hosts  []string `json:"Hosts"`
+}
+
+// JSONOccurrence is a single occurrence of a host: the directive,
+// the header or meta tag (enforced or report-only) and the original
+// source expression.
+type JSONOccurrence struct {
+	Hop         int    `json:"Hop,omitempty"`
+	Directive   string `json:"Directive"`
+	Origin      string `json:"Origin"`
+	Disposition string `json:"Disposition,omitempty"`
+	Expression  string `json:"Expression"`
+	Scheme      string `json:"Scheme,omitempty"`
+	Port        string `json:"Port,omitempty"`
+	Path        string `json:"Path,omitempty"`
+}
+
+// JSONSource is a classified source expression.
+type JSONSource struct {
+	Hop         int    `json:"Hop,omitempty"`
+	Directive   string `json:"Directive"`
+	Origin      string `json:"Origin"`
+	Disposition string `json:"Disposition,omitempty"`
+	Expression  string `json:"Expression"`
+	Type        string `json:"Type"`
+	Scheme      string `json:"Scheme,omitempty"`
+	Host        string `json:"Host,omitempty"`
+	Port        string `json:"Port,omitempty"`
+	Path        string `json:"Path,omitempty"`
+	Value       string `json:"Value,omitempty"`
+	Algorithm   string `json:"Algorithm,omitempty"`
+}
+
+// JSONWeakness is an issue found evaluating a policy.
+type JSONWeakness struct {
+	RuleID      string `json:"RuleID"`
+	Severity    string `json:"Severity"`
+	Directive   string `json:"Directive,omitempty"`
+	Origin      string `json:"Origin,omitempty"`
+	Description string `json:"Description"`
+}
+
+// JSONBypass is an allowlisted source enabling a known bypass technique.
+type JSONBypass struct {
+	Directive  string `json:"Directive"`
+	Origin     string `json:"Origin"`
+	Expression string `json:"Expression"`
+	Host       string `json:"Host"`
+	Technique  string `json:"Technique"`
+	Example    string `json:"Example"`
+}
+
+// JSONPolicyDiff is a source expression delivered only to some
+// User-Agents (the directive itself if Expression is empty).
+type JSONPolicyDiff struct {
+	Directive  string   `json:"Directive"`
+	Origin     string   `json:"Origin"`
+	Expression string   `json:"Expression,omitempty"`
+	Present    []string `json:"Present"`
+	Missing    []string `json:"Missing"`
+}
+
+// JSONReportEndpoint is a CSP reporting endpoint.
+type JSONReportEndpoint struct {
+	Directive string `json:"Directive"`
+	Origin    string `json:"Origin"`
+	Group     string `json:"Group,omitempty"`
+	URL       string `json:"URL,omitempty"`
+}
+
+// JSONEffective holds the effective allowlists of the enforced
+// and of the report-only policies.
+type JSONEffective struct {
+	Enforced   []JSONEffectiveDirective `json:"Enforced"`
+	ReportOnly []JSONEffectiveDirective `json:"ReportOnly"`
+}
+
+// JSONEffectiveDirective is the effective allowlist of a directive.
+type JSONEffectiveDirective struct {
+	Directive    string                `json:"Directive"`
+	Unrestricted bool                  `json:"Unrestricted"`
+	Sources      []JSONEffectiveSource `json:"Sources"`
+}
+
+// JSONEffectiveSource is a source expression allowed by every policy.
+type JSONEffectiveSource struct {
+	Expression string `json:"Expression"`
+	Type       string `json:"Type"`
+	Directive  string `json:"Directive"`
+	Origin     string `json:"Origin"`
 }
 
 // FormatJSON returns the input as JSON string.
 func FormatJSON(url string, result []string) ([]byte, error) {
-	input := &JSONData{
+	return FormatJSONData(&JSONData{
 		URL:       url,
 		CSPResult: result,
-	}
+	})
+}
 
-	jsonOutput, err := json.Marshal(input)
+// FormatJSONData returns the input data as JSON string.
+func FormatJSONData(data *JSONData) ([]byte, error) {
+	jsonOutput, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
 	}